@@ -1,7 +1,13 @@
 package sawyer
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
 	"runtime"
+	"sync"
+	"syscall"
 
 	homedir "github.com/mitchellh/go-homedir"
 
@@ -10,6 +16,7 @@ import (
 	"path/filepath"
 
 	"github.com/juju/loggo"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/txomon/sawyer/pkg/de"
 	"github.com/txomon/sawyer/pkg/provider"
@@ -18,6 +25,10 @@ import (
 
 var logger = loggo.GetLogger("sawyer")
 
+// shutdownTimeout is how long providers get to finish their downloads and
+// cache writes once sawyer is asked to stop.
+var shutdownTimeout = 30 * time.Second
+
 func configure() error {
 	//jww.SetLogThreshold(jww.LevelTrace)
 	//jww.SetStdoutThreshold(jww.LevelTrace)
@@ -32,6 +43,17 @@ func configure() error {
 	viper.SetDefault(util.ConfigurationChangeInterval, 10)
 	viper.SetDefault(util.ConfigurationCacheDir, "cache")
 	viper.SetDefault(util.ConfigurationProviders, make([]interface{}, 0))
+	viper.SetDefault(util.ConfigurationDesktop, "")
+	viper.SetDefault(util.ConfigurationWallpaperMode, wallpaperModeSame)
+	viper.SetDefault(util.ConfigurationSpanBezel, 0)
+	viper.SetDefault(util.ConfigurationExports, make([]interface{}, 0))
+	viper.SetDefault(util.ConfigurationTargets, make([]interface{}, 0))
+	viper.SetDefault(util.ConfigurationApplyRetries, 2)
+	viper.SetDefault(util.ConfigurationApplyRetryDelay, 2)
+	viper.SetDefault(util.ConfigurationApplyFallbacks, 3)
+	viper.SetDefault(util.ConfigurationLockScreenEnabled, false)
+	viper.SetDefault(util.ConfigurationLockScreenFollowDesktop, true)
+	viper.SetDefault(util.ConfigurationLockScreenProviders, make([]interface{}, 0))
 
 	// Load config
 	err := viper.ReadInConfig()
@@ -40,13 +62,65 @@ func configure() error {
 		return err
 	}
 
+	// Defaults depending on other settings
+	viper.SetDefault(util.ConfigurationLockScreenCacheDir, filepath.Join(viper.GetString(util.ConfigurationCacheDir), ".lock-screen"))
+	viper.SetDefault(util.ConfigurationLockScreenChangeInterval, viper.GetInt(util.ConfigurationChangeInterval))
+
 	//loggo.GetLogger("sawyer.util").SetLogLevel(loggo.INFO)
 	return nil
 }
 
-func DaemonMain() {
-	pictureStream := make(chan string)
+func parseFlags() error {
+	pflag.String("de", "", "Desktop environment to use instead of detecting it")
+	pflag.Parse()
+	return viper.BindPFlag(util.ConfigurationDesktop, pflag.Lookup("de"))
+}
+
+// signalContext is cancelled on the first SIGINT or SIGTERM. A second one
+// gets the default behaviour, so a stuck shutdown can still be killed.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case received := <-signals:
+			logger.Infof("Received %v, shutting down", received)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+// waitFor waits for the running goroutines, giving up after timeout
+func waitFor(running *sync.WaitGroup, timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("providers did not stop within %v", timeout)
+	}
+}
+
+func DaemonMain() error {
+	if err := parseFlags(); err != nil {
+		return err
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+	return Run(ctx)
+}
 
+// Run changes the background until ctx is done, then waits for the
+// providers to finish what they were downloading.
+func Run(ctx context.Context) error {
 	home, isHome := homedir.Dir()
 	switch runtime.GOOS {
 	case "linux":
@@ -63,24 +137,193 @@ func DaemonMain() {
 			viper.AddConfigPath(filepath.Join(home, "Library/Preferences/sawyer"))
 		}
 	default:
-		logger.Errorf("OS %v is not supported by background changer", runtime.GOOS)
-		return
+		return fmt.Errorf("OS %v is not supported by background changer", runtime.GOOS)
 	}
-	obc := de.GetDEBackgroundChanger("")
 
 	err := configure()
 	for err != nil {
 		logger.Infof("Retrying in 10 seconds")
-		time.Sleep(time.Duration(10000000000))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Duration(10000000000)):
+		}
 		err = configure()
 	}
 
-	for _, supportedFormat := range obc.GetSupportedFormats() {
-		util.RegisterSupportedFormat(supportedFormat)
+	de.SetWorkDirectory(util.GetWorkDir(viper.GetString(util.ConfigurationCacheDir)))
+	builder := &targetBuilder{}
+	targetConfigs := viper.Get(util.ConfigurationTargets).([]interface{})
+	if len(targetConfigs) == 0 {
+		targetConfigs = builder.legacyTargets()
+	}
+	targets := builder.buildAll(targetConfigs)
+	if len(targets.Targets) == 0 {
+		return fmt.Errorf("none of the targets %v could be set up", targetConfigs)
 	}
+	registerFormats(targets)
+
 	providerConfigs := viper.Get(util.ConfigurationProviders).([]interface{})
 	logger.Infof("Read config for providers %v", providerConfigs)
-	provider.RunProviders(viper.GetString(util.ConfigurationCacheDir), providerConfigs)
-	go pictureMonitor(pictureStream)
-	obc.Set(pictureStream)
+	var running sync.WaitGroup
+	provider.RunProviders(ctx, &running, viper.GetString(util.ConfigurationCacheDir), providerConfigs)
+
+	if viper.GetBool(util.ConfigurationLockScreenEnabled) && !viper.GetBool(util.ConfigurationLockScreenFollowDesktop) {
+		runLockScreen(ctx, &running, builder)
+	}
+
+	monitor := &pictureMonitor{targets: targets, config: desktopMonitor}
+	monitor.run(ctx)
+	logger.Infof("Waiting for providers to finish")
+	if err := waitFor(&running, shutdownTimeout); err != nil {
+		return err
+	}
+	logger.Infof("Stopped")
+	return nil
+}
+
+const (
+	targetDesktop    = "desktop"
+	targetLockScreen = "lock_screen"
+	targetExport     = "export"
+)
+
+// targetBuilder sets up targets out of their configuration, detecting the
+// desktop environment only when a target needs it.
+type targetBuilder struct {
+	desktop string
+}
+
+func (tb *targetBuilder) getDesktop() (string, error) {
+	if tb.desktop != "" {
+		return tb.desktop, nil
+	}
+	desktop := viper.GetString(util.ConfigurationDesktop)
+	if desktop == "" {
+		detected, err := de.DetectDE(viper.GetStringMap(util.ConfigurationDesktopOptions))
+		if err != nil {
+			return "", fmt.Errorf("could not set up the desktop environment: %v", err)
+		}
+		desktop = detected
+	}
+	tb.desktop = desktop
+	return desktop, nil
+}
+
+// legacyTargets are the targets of configurations not listing them: the
+// desktop, the lock screen when it follows it and the exports.
+func (tb *targetBuilder) legacyTargets() []interface{} {
+	targets := []interface{}{map[string]interface{}{"type": targetDesktop}}
+	if viper.GetBool(util.ConfigurationLockScreenEnabled) && viper.GetBool(util.ConfigurationLockScreenFollowDesktop) {
+		targets = append(targets, map[string]interface{}{"type": targetLockScreen})
+	}
+	for _, exportConfig := range viper.Get(util.ConfigurationExports).([]interface{}) {
+		config, ok := exportConfig.(map[string]interface{})
+		if !ok {
+			logger.Warningf("Export %v is not an object, skipping it", exportConfig)
+			continue
+		}
+		// Exports never made a change fail before there were targets
+		target := map[string]interface{}{"type": targetExport, "optional": true}
+		for key, value := range config {
+			target[key] = value
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func (tb *targetBuilder) build(config map[string]interface{}) (*de.Target, error) {
+	targetType, _ := config["type"].(string)
+	target := &de.Target{Name: targetType}
+	var err error
+	switch targetType {
+	case "":
+		return nil, fmt.Errorf("target has no type")
+	case targetDesktop:
+		var desktop string
+		if desktop, err = tb.getDesktop(); err != nil {
+			return nil, err
+		}
+		target.Name = desktop
+		target.Primary = true
+		target.BackgroundChanger, err = de.GetDEBackgroundChanger(desktop, viper.GetStringMap(util.ConfigurationDesktopOptions))
+	case targetLockScreen:
+		var desktop string
+		if desktop, err = tb.getDesktop(); err != nil {
+			return nil, err
+		}
+		target.Name = fmt.Sprintf("%v-%v", desktop, targetLockScreen)
+		target.BackgroundChanger, err = de.GetLockScreenBackgroundChanger(desktop, viper.GetStringMap(util.ConfigurationLockScreenOptions))
+	default:
+		target.BackgroundChanger, err = de.GetDEBackgroundChanger(targetType, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := config["name"].(string); ok && name != "" {
+		target.Name = name
+	}
+	target.Optional, _ = config["optional"].(bool)
+	if formats, ok := config["formats"].([]interface{}); ok {
+		for _, format := range formats {
+			if format, ok := format.(string); ok {
+				target.Formats = append(target.Formats, format)
+			}
+		}
+	}
+	return target, nil
+}
+
+// buildAll sets up every target it can, a target failing doesn't keep the
+// others from being used.
+func (tb *targetBuilder) buildAll(configs []interface{}) *de.FanOut {
+	targets := de.NewFanOut()
+	for _, targetConfig := range configs {
+		config, ok := targetConfig.(map[string]interface{})
+		if !ok {
+			logger.Warningf("Target %v is not an object, skipping it", targetConfig)
+			continue
+		}
+		target, err := tb.build(config)
+		if err != nil {
+			logger.Warningf("Target %v could not be set up, skipping it. %v", config, err)
+			continue
+		}
+		logger.Infof("Changing the background of %v", target)
+		targets.Targets = append(targets.Targets, target)
+	}
+	return targets
+}
+
+// registerFormats lets providers keep the pictures in the formats the
+// primary target of targets can use. Each monitor only picks those of its
+// own targets, see pictureMonitor.usable.
+func registerFormats(targets *de.FanOut) {
+	for _, format := range targets.Formats() {
+		util.RegisterSupportedFormat(format)
+	}
+}
+
+// runLockScreen starts changing the lock screen background with its own
+// providers, when it doesn't follow the desktop.
+func runLockScreen(ctx context.Context, running *sync.WaitGroup, builder *targetBuilder) {
+	target, err := builder.build(map[string]interface{}{"type": targetLockScreen})
+	if err != nil {
+		logger.Warningf("Not changing the lock screen background. %v", err)
+		return
+	}
+	target.Primary = true
+	targets := de.NewFanOut(target)
+	registerFormats(targets)
+
+	providerConfigs := viper.Get(util.ConfigurationLockScreenProviders).([]interface{})
+	logger.Infof("Read config for lock screen providers %v", providerConfigs)
+	provider.RunProviders(ctx, running, viper.GetString(util.ConfigurationLockScreenCacheDir), providerConfigs)
+	monitor := &pictureMonitor{targets: targets, config: lockScreenMonitor}
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		monitor.run(ctx)
+	}()
 }
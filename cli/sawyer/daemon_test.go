@@ -0,0 +1,48 @@
+package sawyer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+func TestLegacyExportsOptional(t *testing.T) {
+	viper.Set(util.ConfigurationExports, []interface{}{map[string]interface{}{"path": filepath.Join(t.TempDir(), "current")}})
+	defer viper.Reset()
+	// The desktop is forced, so that building it doesn't detect anything
+	builder := &targetBuilder{desktop: "export"}
+	configs := builder.legacyTargets()
+	if len(configs) != 2 {
+		t.Fatalf("Expected the desktop and an export, got %v", configs)
+	}
+	export, err := builder.build(configs[1].(map[string]interface{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !export.Optional {
+		t.Errorf("Export of a configuration without targets is not optional")
+	}
+
+	required, err := builder.build(map[string]interface{}{"type": "export", "path": filepath.Join(t.TempDir(), "other")})
+	if err != nil || required.Optional {
+		t.Errorf("Target without optional is optional, %v", err)
+	}
+}
+
+func TestDesktopTargetPrimary(t *testing.T) {
+	viper.Set(util.ConfigurationDesktopOptions, map[string]interface{}{"path": filepath.Join(t.TempDir(), "desktop")})
+	defer viper.Reset()
+	builder := &targetBuilder{desktop: "export"}
+	targets := builder.buildAll([]interface{}{
+		map[string]interface{}{"type": "export", "path": filepath.Join(t.TempDir(), "first")},
+		map[string]interface{}{"type": targetDesktop},
+	})
+	if len(targets.Targets) != 2 || targets.Targets[0].Primary || !targets.Targets[1].Primary {
+		t.Fatalf("Expected only the desktop target as primary, got %v", targets.Targets)
+	}
+	if targets.Primary() != targets.Targets[1].BackgroundChanger {
+		t.Errorf("Wallpapers not laid out on the desktop")
+	}
+}
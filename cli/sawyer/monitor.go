@@ -1,13 +1,40 @@
 package sawyer
 
 import (
+	"context"
 	"os"
 	"time"
 
 	"github.com/spf13/viper"
+	"github.com/txomon/sawyer/pkg/de"
 	"github.com/txomon/sawyer/pkg/util"
 )
 
+const (
+	wallpaperModeSame      = "same"
+	wallpaperModePerOutput = "per_output"
+	wallpaperModeSpan      = "span"
+)
+
+// monitorConfig holds the configuration keys a picture monitor reads, so
+// the desktop and the lock screen can rotate from different sources.
+type monitorConfig struct {
+	cacheDir       string
+	changeInterval string
+	wallpaperMode  string
+}
+
+var desktopMonitor = monitorConfig{
+	cacheDir:       util.ConfigurationCacheDir,
+	changeInterval: util.ConfigurationChangeInterval,
+	wallpaperMode:  util.ConfigurationWallpaperMode,
+}
+
+var lockScreenMonitor = monitorConfig{
+	cacheDir:       util.ConfigurationLockScreenCacheDir,
+	changeInterval: util.ConfigurationLockScreenChangeInterval,
+}
+
 func getNextInList(lastItem string, previousList []string, nextList []string) string {
 	found := false
 	for _, nextItem := range previousList {
@@ -31,31 +58,202 @@ func getNextInList(lastItem string, previousList []string, nextList []string) st
 	return ""
 }
 
-func pictureMonitor(pictureStream chan string) {
-	var lastFile, nextFile string
-	var lastFileList, nextFileList []string
+// getNextForOutputs advances the picture of every output on its own. New
+// outputs start at different pictures, and a picture another output is
+// already showing is skipped while there are unused ones.
+func getNextForOutputs(outputs []de.Output, lastFiles map[string]string, previousList []string, nextList []string) map[string]string {
+	nextFiles := make(map[string]string)
+	used := make(map[string]bool)
+	for index, output := range outputs {
+		var nextFile string
+		if lastFile, ok := lastFiles[output.Name]; ok {
+			nextFile = getNextInList(lastFile, previousList, nextList)
+		} else if len(nextList) > 0 {
+			nextFile = nextList[index%len(nextList)]
+		}
+		for skipped := 0; used[nextFile] && len(used) < len(nextList) && skipped < len(nextList); skipped++ {
+			nextFile = getNextInList(nextFile, nextList, nextList)
+		}
+		used[nextFile] = true
+		nextFiles[output.Name] = nextFile
+	}
+	return nextFiles
+}
 
-	for {
-		cachePath := viper.GetString(util.ConfigurationCacheDir)
-		_, err := os.Stat(cachePath)
-		if err != nil {
-			os.MkdirAll(cachePath, 0755)
+// getOutputs returns the outputs to give a different picture to, or nil
+// when all of them show the same one.
+func getOutputs(obc de.DEBackgroundChanger, mode string) []de.Output {
+	if mode != wallpaperModePerOutput && mode != wallpaperModeSpan {
+		return nil
+	}
+	if obc == nil {
+		logger.Warningf("There is no desktop to lay the pictures out on its outputs, using the same in all")
+		return nil
+	}
+	outputBackgroundChanger, ok := obc.(de.OutputBackgroundChanger)
+	if !ok {
+		logger.Warningf("Desktop environment can't set a picture per output, using the same in all")
+		return nil
+	}
+	outputs, err := outputBackgroundChanger.GetOutputs()
+	if err != nil {
+		logger.Warningf("Failed to get outputs, using the same picture in all. %v", err)
+		return nil
+	}
+	return outputs
+}
+
+// removeStaleSlices deletes the slices of the last spanned picture that are
+// not being shown anymore.
+func removeStaleSlices(lastSlices map[string]string, slices map[string]string) {
+	inUse := make(map[string]bool)
+	for _, slice := range slices {
+		inUse[slice] = true
+	}
+	for _, slice := range lastSlices {
+		if !inUse[slice] {
+			os.Remove(slice)
 		}
-		nextFileList = util.GetPhotosForPath(cachePath)
+	}
+}
 
-		nextFile = getNextInList(lastFile, lastFileList, nextFileList)
+// pictureMonitor rotates the background of its targets through the
+// pictures in its cache dir, laid out on the outputs of the primary target.
+type pictureMonitor struct {
+	targets *de.FanOut
+	config  monitorConfig
+
+	lastFile        string
+	lastFileList    []string
+	lastOutputFiles map[string]string
+	lastSlices      map[string]string
+}
+
+// nextWallpaper picks the wallpaper after the last one and makes it the
+// last one, so calling it again after a failure moves on to the next.
+func (pm *pictureMonitor) nextWallpaper(fileList []string) (de.Wallpaper, bool) {
+	var nextFile string
+	var nextOutputFiles map[string]string
+
+	wallpaper := de.Wallpaper{}
+	mode := wallpaperModeSame
+	if pm.config.wallpaperMode != "" {
+		mode = viper.GetString(pm.config.wallpaperMode)
+	}
+	outputs := getOutputs(pm.targets.Primary(), mode)
+	if outputs != nil && mode == wallpaperModePerOutput {
+		nextOutputFiles = getNextForOutputs(outputs, pm.lastOutputFiles, pm.lastFileList, fileList)
+		wallpaper.Outputs = make(map[string]string)
+		for _, output := range outputs {
+			if outputFile := nextOutputFiles[output.Name]; outputFile != "" {
+				wallpaper.Outputs[output.Name] = outputFile
+				if nextFile == "" {
+					nextFile = outputFile
+				}
+			}
+		}
+	} else {
+		nextFile = getNextInList(pm.lastFile, pm.lastFileList, fileList)
+	}
+	wallpaper.Picture = nextFile
 
-		if _, err := os.Stat(nextFile); err == nil {
-			logger.Infof("Next background %v", nextFile)
-			pictureStream <- nextFile
+	if outputs != nil && mode == wallpaperModeSpan && nextFile != "" {
+		slices, err := de.SpanPicture(nextFile, outputs, viper.GetInt(util.ConfigurationSpanBezel))
+		if err != nil {
+			logger.Warningf("Failed to span %v over the outputs, using it in all. %v", nextFile, err)
 		} else {
+			wallpaper.Outputs = slices
+			removeStaleSlices(pm.lastSlices, slices)
+			pm.lastSlices = slices
+		}
+	}
+
+	pm.lastFile = nextFile
+	pm.lastFileList = fileList
+	pm.lastOutputFiles = nextOutputFiles
+
+	if _, err := os.Stat(nextFile); err != nil {
+		return wallpaper, false
+	}
+	return wallpaper, true
+}
+
+// usable leaves out the pictures in formats the targets can't use, which
+// providers may keep for other monitors.
+func (pm *pictureMonitor) usable(fileList []string) []string {
+	formats := pm.targets.Formats()
+	usable := make([]string, 0, len(fileList))
+	for _, file := range fileList {
+		if de.CheckFormats(de.Wallpaper{Picture: file}, formats) == nil {
+			usable = append(usable, file)
+		}
+	}
+	return usable
+}
+
+// change applies the next wallpaper. When a target that isn't optional can't
+// apply it even after retrying, the ones after it are tried, up to the
+// configured fallbacks.
+func (pm *pictureMonitor) change(ctx context.Context) {
+	cachePath := viper.GetString(pm.config.cacheDir)
+	_, err := os.Stat(cachePath)
+	if err != nil {
+		os.MkdirAll(cachePath, 0755)
+	}
+	fileList := pm.usable(util.GetPhotosForPath(cachePath))
+
+	retries := viper.GetInt(util.ConfigurationApplyRetries)
+	delay := viper.GetDuration(util.ConfigurationApplyRetryDelay) * time.Second
+	fallbacks := viper.GetInt(util.ConfigurationApplyFallbacks)
+	tried := make(map[string]bool)
+	for fallback := 0; fallback <= fallbacks && ctx.Err() == nil; fallback++ {
+		wallpaper, ok := pm.nextWallpaper(fileList)
+		if !ok {
 			logger.Infof("There is no background file available")
+			return
+		}
+		if tried[wallpaper.Picture] {
+			logger.Warningf("Every background available failed to apply")
+			return
 		}
+		tried[wallpaper.Picture] = true
+
+		logger.Infof("Next background %v", wallpaper)
+		results := pm.targets.Apply(ctx, wallpaper, retries, delay)
+		for _, result := range results {
+			if result.Err == nil {
+				logger.Infof("Background %v %v", wallpaper.Picture, result)
+			} else {
+				logger.Warningf("Background %v %v", wallpaper.Picture, result)
+			}
+		}
+		for _, target := range pm.targets.Targets {
+			logger.Debugf("Background of %v so far: %v", target, target.Stats())
+		}
+		if pm.targets.Applied(results) {
+			return
+		}
+	}
+}
+
+// run changes the background every change interval until ctx is done
+func (pm *pictureMonitor) run(ctx context.Context) {
+	defer pm.close()
+	for {
+		pm.change(ctx)
 
 		// We wait for Duration before changing again
-		configuredDuration := viper.GetDuration(util.ConfigurationChangeInterval)
-		time.Sleep(configuredDuration * 1000000000)
-		lastFile, nextFile = nextFile, ""
-		lastFileList, nextFileList = nextFileList, nil
+		configuredDuration := viper.GetDuration(pm.config.changeInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(configuredDuration * 1000000000):
+		}
+	}
+}
+
+func (pm *pictureMonitor) close() {
+	if err := pm.targets.Close(); err != nil {
+		logger.Warningf("%v", err)
 	}
 }
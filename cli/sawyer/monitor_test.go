@@ -0,0 +1,182 @@
+package sawyer
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/txomon/sawyer/pkg/de"
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+func TestGetNextForOutputs(t *testing.T) {
+	outputs := []de.Output{{Name: "eDP-1"}, {Name: "HDMI-1"}}
+	pictures := []string{"a", "b", "c"}
+
+	// New outputs start at different pictures
+	next := getNextForOutputs(outputs, map[string]string{}, nil, pictures)
+	if expected := map[string]string{"eDP-1": "a", "HDMI-1": "b"}; !reflect.DeepEqual(next, expected) {
+		t.Errorf("Expected %v, got %v", expected, next)
+	}
+
+	// Each output advances on its own, skipping the picture already shown
+	next = getNextForOutputs(outputs, map[string]string{"eDP-1": "a", "HDMI-1": "a"}, pictures, pictures)
+	if expected := map[string]string{"eDP-1": "b", "HDMI-1": "c"}; !reflect.DeepEqual(next, expected) {
+		t.Errorf("Expected %v, got %v", expected, next)
+	}
+
+	// With fewer pictures than outputs they are repeated
+	next = getNextForOutputs(outputs, map[string]string{}, nil, []string{"a"})
+	if expected := map[string]string{"eDP-1": "a", "HDMI-1": "a"}; !reflect.DeepEqual(next, expected) {
+		t.Errorf("Expected %v, got %v", expected, next)
+	}
+}
+
+func TestRemoveStaleSlices(t *testing.T) {
+	directory := t.TempDir()
+	paths := make(map[string]string)
+	for _, name := range []string{"old", "kept", "new"} {
+		paths[name] = filepath.Join(directory, name+".jpg")
+		if err := ioutil.WriteFile(paths[name], nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removeStaleSlices(
+		map[string]string{"eDP-1": paths["old"], "HDMI-1": paths["kept"]},
+		map[string]string{"eDP-1": paths["kept"], "HDMI-1": paths["new"]},
+	)
+	for name, exists := range map[string]bool{"old": false, "kept": true, "new": true} {
+		if _, err := os.Stat(paths[name]); (err == nil) != exists {
+			t.Errorf("Slice %v exists is %v, expected %v", name, err == nil, exists)
+		}
+	}
+}
+
+// fakeBackgroundChanger fails to apply the pictures in failing
+type fakeBackgroundChanger struct {
+	failing map[string]bool
+	applied []string
+}
+
+func (fbc *fakeBackgroundChanger) Apply(wallpaper de.Wallpaper) error {
+	if fbc.failing[filepath.Base(wallpaper.Picture)] {
+		return errors.New("broken picture")
+	}
+	fbc.applied = append(fbc.applied, filepath.Base(wallpaper.Picture))
+	return nil
+}
+
+func (fbc *fakeBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"png"}
+}
+
+func TestPictureMonitorFallback(t *testing.T) {
+	util.RegisterSupportedFormat("png")
+	cacheDir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := util.SaveImage(image.NewGray(image.Rect(0, 0, 4, 4)), filepath.Join(cacheDir, name+".png")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	viper.Set(util.ConfigurationCacheDir, cacheDir)
+	viper.Set(util.ConfigurationApplyFallbacks, 1)
+	defer viper.Reset()
+
+	desktop := &fakeBackgroundChanger{failing: map[string]bool{"a.png": true, "b.png": true}}
+	lockScreen := &fakeBackgroundChanger{failing: map[string]bool{"a.png": true, "b.png": true}}
+	targets := de.NewFanOut(&de.Target{Name: "desktop", BackgroundChanger: desktop}, &de.Target{Name: "lock-screen", BackgroundChanger: lockScreen})
+	pm := &pictureMonitor{targets: targets, config: desktopMonitor}
+
+	// a and b fail everywhere, one fallback is not enough
+	pm.change(context.Background())
+	if len(desktop.applied) != 0 || len(lockScreen.applied) != 0 {
+		t.Errorf("Expected nothing applied, got %v and %v", desktop.applied, lockScreen.applied)
+	}
+	// The next change continues from b
+	pm.change(context.Background())
+	if expected := []string{"c.png"}; !reflect.DeepEqual(desktop.applied, expected) || !reflect.DeepEqual(lockScreen.applied, expected) {
+		t.Errorf("Expected %v applied, got %v and %v", expected, desktop.applied, lockScreen.applied)
+	}
+}
+
+func TestPictureMonitorRequiredTargets(t *testing.T) {
+	util.RegisterSupportedFormat("png")
+	cacheDir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := util.SaveImage(image.NewGray(image.Rect(0, 0, 4, 4)), filepath.Join(cacheDir, name+".png")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	viper.Set(util.ConfigurationCacheDir, cacheDir)
+	viper.Set(util.ConfigurationApplyFallbacks, 1)
+	defer viper.Reset()
+
+	desktop := &fakeBackgroundChanger{failing: map[string]bool{"a.png": true}}
+	export := &fakeBackgroundChanger{failing: map[string]bool{"b.png": true}}
+	targets := de.NewFanOut(&de.Target{Name: "desktop", BackgroundChanger: desktop}, &de.Target{Name: "export", BackgroundChanger: export, Optional: true})
+	pm := &pictureMonitor{targets: targets, config: desktopMonitor}
+
+	// The export applying a doesn't count, the desktop has to, and the
+	// export failing with b doesn't make it fall back any further
+	pm.change(context.Background())
+	if !reflect.DeepEqual(desktop.applied, []string{"b.png"}) || !reflect.DeepEqual(export.applied, []string{"a.png"}) {
+		t.Errorf("Expected b on the desktop and a exported, got %v and %v", desktop.applied, export.applied)
+	}
+	if stats := targets.Targets[0].Stats(); stats.Applied != 1 || stats.Failed != 1 {
+		t.Errorf("Unexpected desktop stats %v", stats)
+	}
+}
+
+func TestPictureMonitorFormats(t *testing.T) {
+	util.RegisterSupportedFormat("png")
+	util.RegisterSupportedFormat("webp")
+	cacheDir := t.TempDir()
+	if err := util.SaveImage(image.NewGray(image.Rect(0, 0, 4, 4)), filepath.Join(cacheDir, "a.png")); err != nil {
+		t.Fatal(err)
+	}
+	// Kept by providers for an export able to use it
+	if err := ioutil.WriteFile(filepath.Join(cacheDir, "b.webp"), []byte("webp"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set(util.ConfigurationCacheDir, cacheDir)
+	defer viper.Reset()
+
+	desktop, export := &fakeBackgroundChanger{}, &fakeBackgroundChanger{}
+	targets := de.NewFanOut(
+		&de.Target{Name: "export", BackgroundChanger: export, Formats: []string{"webp"}, Optional: true},
+		&de.Target{Name: "desktop", BackgroundChanger: desktop, Primary: true},
+	)
+	pm := &pictureMonitor{targets: targets, config: desktopMonitor}
+	for change := 0; change < 2; change++ {
+		pm.change(context.Background())
+	}
+	if !reflect.DeepEqual(desktop.applied, []string{"a.png", "a.png"}) {
+		t.Errorf("Expected only a.png picked, got %v", desktop.applied)
+	}
+}
+
+func TestPictureMonitorStops(t *testing.T) {
+	viper.Set(util.ConfigurationCacheDir, t.TempDir())
+	viper.Set(util.ConfigurationChangeInterval, 3600)
+	defer viper.Reset()
+	pm := &pictureMonitor{targets: de.NewFanOut(&de.Target{Name: "desktop", BackgroundChanger: &fakeBackgroundChanger{}}), config: desktopMonitor}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		pm.run(ctx)
+		close(stopped)
+	}()
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Picture monitor kept running after shutdown")
+	}
+}
@@ -1,3 +1,4 @@
 go get github.com/spf13/viper
+go get github.com/spf13/pflag
 go get github.com/juju/loggo
 go get github.com/mitchellh/go-homedir
@@ -5,5 +5,6 @@ go 1.15
 require (
 	github.com/juju/loggo v0.0.0-20200526014432-9ce3a2e09b5e
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.7.1
 )
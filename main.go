@@ -1,7 +1,15 @@
 package main
 
-import "github.com/txomon/sawyer/cli/sawyer"
+import (
+	"fmt"
+	"os"
+
+	"github.com/txomon/sawyer/cli/sawyer"
+)
 
 func main() {
-	sawyer.DaemonMain()
+	if err := sawyer.DaemonMain(); err != nil {
+		fmt.Fprintf(os.Stderr, "sawyer: %v\n", err)
+		os.Exit(1)
+	}
 }
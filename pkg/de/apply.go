@@ -0,0 +1,127 @@
+package de
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedFormat = errors.New("picture format is not supported")
+
+// RetryableError is a failure that may go away applying the same wallpaper
+// again, like a desktop service that didn't answer in time.
+type RetryableError struct {
+	Err error
+}
+
+func (re RetryableError) Error() string {
+	return re.Err.Error()
+}
+
+func (re RetryableError) Unwrap() error {
+	return re.Err
+}
+
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return RetryableError{Err: err}
+}
+
+func IsRetryable(err error) bool {
+	var retryable RetryableError
+	return errors.As(err, &retryable)
+}
+
+const (
+	StatusApplied     = "applied"
+	StatusRetryable   = "retryable"
+	StatusUnsupported = "unsupported"
+	StatusFailed      = "failed"
+)
+
+// Result is the outcome of applying a wallpaper, Latency covers every
+// attempt including the time waited between them.
+type Result struct {
+	Target    string
+	Wallpaper Wallpaper
+	Err       error
+	Attempts  int
+	Latency   time.Duration
+}
+
+func (r Result) Status() string {
+	switch {
+	case r.Err == nil:
+		return StatusApplied
+	case errors.Is(r.Err, ErrUnsupportedFormat):
+		return StatusUnsupported
+	case IsRetryable(r.Err):
+		return StatusRetryable
+	}
+	return StatusFailed
+}
+
+func (r Result) String() string {
+	description := fmt.Sprintf("%v after %v attempts in %v", r.Status(), r.Attempts, r.Latency)
+	if r.Target != "" {
+		description = fmt.Sprintf("%v on %v", description, r.Target)
+	}
+	if r.Err != nil {
+		description = fmt.Sprintf("%v: %v", description, r.Err)
+	}
+	return description
+}
+
+// CheckFormats fails with ErrUnsupportedFormat when any picture of the
+// wallpaper has an extension other than the given formats.
+func CheckFormats(wallpaper Wallpaper, formats []string) error {
+	pictures := []string{wallpaper.Picture}
+	for _, picture := range wallpaper.Outputs {
+		pictures = append(pictures, picture)
+	}
+	for _, picture := range pictures {
+		extension := strings.ToLower(strings.TrimPrefix(filepath.Ext(picture), "."))
+		supported := false
+		for _, format := range formats {
+			if extension == format {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("%w: %v", ErrUnsupportedFormat, picture)
+		}
+	}
+	return nil
+}
+
+// Apply sets the wallpaper, retrying up to retries times waiting delay
+// between attempts as long as the failure is retryable and ctx is not done.
+func Apply(ctx context.Context, backgroundChanger DEBackgroundChanger, wallpaper Wallpaper, retries int, delay time.Duration) Result {
+	start := time.Now()
+	result := Result{Wallpaper: wallpaper}
+	if result.Err = CheckFormats(wallpaper, backgroundChanger.GetSupportedFormats()); result.Err != nil {
+		return result
+	}
+	for {
+		result.Attempts++
+		result.Err = backgroundChanger.Apply(wallpaper)
+		if result.Err == nil || !IsRetryable(result.Err) || result.Attempts > retries {
+			break
+		}
+		logger.Infof("Applying %v failed, retrying in %v. %v", wallpaper.Picture, delay, result.Err)
+		select {
+		case <-ctx.Done():
+			result.Latency = time.Since(start)
+			return result
+		case <-time.After(delay):
+		}
+	}
+	result.Latency = time.Since(start)
+	return result
+}
@@ -0,0 +1,56 @@
+package de
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApplyRetries(t *testing.T) {
+	busy := Retryable(errors.New("busy"))
+	fbc := &fakeBackgroundChanger{errs: []error{busy, busy}}
+	result := Apply(context.Background(), fbc, Wallpaper{Picture: "/pictures/a.jpg"}, 2, time.Millisecond)
+	if result.Err != nil || result.Attempts != 3 || result.Status() != StatusApplied {
+		t.Errorf("Expected to apply on the third attempt, got %v", result)
+	}
+	if result.Latency < 2*time.Millisecond {
+		t.Errorf("Latency %v doesn't cover the retry delays", result.Latency)
+	}
+
+	fbc = &fakeBackgroundChanger{errs: []error{busy, busy}}
+	if result := Apply(context.Background(), fbc, Wallpaper{Picture: "/pictures/a.jpg"}, 1, 0); result.Status() != StatusRetryable || result.Attempts != 2 {
+		t.Errorf("Expected to give up after one retry, got %v", result)
+	}
+
+	fbc = &fakeBackgroundChanger{errs: []error{errors.New("broken")}}
+	if result := Apply(context.Background(), fbc, Wallpaper{Picture: "/pictures/a.jpg"}, 2, 0); result.Status() != StatusFailed || result.Attempts != 1 {
+		t.Errorf("Expected to fail without retrying, got %v", result)
+	}
+}
+
+func TestApplyUnsupportedFormat(t *testing.T) {
+	fbc := &fakeBackgroundChanger{}
+	wallpaper := Wallpaper{Picture: "/pictures/a.jpg", Outputs: map[string]string{"HDMI-1": "/pictures/b.webp"}}
+	result := Apply(context.Background(), fbc, wallpaper, 2, 0)
+	if result.Status() != StatusUnsupported || !errors.Is(result.Err, ErrUnsupportedFormat) {
+		t.Errorf("Expected the webp picture to be unsupported, got %v", result)
+	}
+	if len(fbc.applied) != 0 {
+		t.Errorf("Unsupported wallpaper applied, %v", fbc.applied)
+	}
+	if err := CheckFormats(Wallpaper{Picture: "/pictures/A.JPG"}, []string{"jpg"}); err != nil {
+		t.Errorf("Extensions are not case sensitive, %v", err)
+	}
+}
+
+func TestApplyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fbc := &fakeBackgroundChanger{errs: []error{Retryable(errors.New("busy"))}}
+	start := time.Now()
+	result := Apply(ctx, fbc, Wallpaper{Picture: "/pictures/a.jpg"}, 3, time.Minute)
+	if result.Attempts != 1 || !IsRetryable(result.Err) || time.Since(start) > 5*time.Second {
+		t.Errorf("Expected to stop retrying on shutdown, got %v", result)
+	}
+}
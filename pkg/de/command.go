@@ -0,0 +1,161 @@
+package de
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var defaultCommandTimeout = 30 * time.Second
+
+// runCommandTimeout runs a program killing it after timeout, stdout and
+// stderr are returned apart so errors can be told from regular output.
+var runCommandTimeout = func(timeout time.Duration, name string, args ...string) ([]byte, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	command := exec.CommandContext(ctx, name, args...)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	err := command.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v", timeout)
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// CommandBackgroundChanger runs a configured program to set the background,
+// its arguments are templates where {path}, {uri} and {output} are replaced
+// with the picture path, its file:// URI and the output name. When the
+// template uses {output} and outputs are configured it's run once per
+// output, otherwise {output} is left empty.
+type CommandBackgroundChanger struct {
+	command []string
+	formats []string
+	outputs []string
+	timeout time.Duration
+}
+
+func (cbc *CommandBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	if len(wallpaper.Outputs) == 0 || !cbc.perOutput() {
+		return cbc.run(wallpaper.Picture, "")
+	}
+	for _, output := range cbc.outputs {
+		if err := cbc.run(wallpaper.GetPicture(output), output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cbc *CommandBackgroundChanger) perOutput() bool {
+	if len(cbc.outputs) == 0 {
+		return false
+	}
+	for _, arg := range cbc.command {
+		if strings.Contains(arg, "{output}") {
+			return true
+		}
+	}
+	return false
+}
+
+func (cbc *CommandBackgroundChanger) run(picture, output string) error {
+	replacer := strings.NewReplacer("{path}", picture, "{uri}", fileURI(picture), "{output}", output)
+	args := make([]string, len(cbc.command))
+	for index, arg := range cbc.command {
+		args[index] = replacer.Replace(arg)
+	}
+	start := time.Now()
+	stdout, stderr, err := runCommandTimeout(cbc.timeout, args[0], args[1:]...)
+	logger.Debugf("Command %v took %v. %s", args, time.Since(start), stdout)
+	if len(stderr) > 0 {
+		logger.Infof("Command %v stderr: %s", args[0], bytes.TrimSpace(stderr))
+	}
+	if err != nil {
+		return Retryable(fmt.Errorf("command %v failed: %v %s", args, err, bytes.TrimSpace(stderr)))
+	}
+	return nil
+}
+
+func (cbc *CommandBackgroundChanger) GetOutputs() ([]Output, error) {
+	if !cbc.perOutput() {
+		return nil, ErrOutputsUnsupported
+	}
+	outputs := make([]Output, len(cbc.outputs))
+	for index, output := range cbc.outputs {
+		outputs[index] = Output{Name: output}
+	}
+	return outputs, nil
+}
+
+func (cbc *CommandBackgroundChanger) GetSupportedFormats() []string {
+	return cbc.formats
+}
+
+// stringList reads a list of strings out of a decoded configuration value
+func stringList(value interface{}) ([]string, bool) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, len(items))
+	for index, item := range items {
+		if list[index], ok = item.(string); !ok {
+			return nil, false
+		}
+	}
+	return list, true
+}
+
+func commandTimeout(config map[string]interface{}) time.Duration {
+	if seconds, ok := config["timeout"].(float64); ok && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return defaultCommandTimeout
+}
+
+// CommandDetect runs the configured detect command, a zero exit status
+// means the desktop environment is the one the command is for.
+func CommandDetect(session *Session) Detection {
+	detect, ok := stringList(session.Config["detect"])
+	if !ok || len(detect) == 0 {
+		return Rejected("no detect command configured")
+	}
+	if _, ok := stringList(session.Config["command"]); !ok {
+		return Rejected("no command configured")
+	}
+	if _, stderr, err := runCommandTimeout(commandTimeout(session.Config), detect[0], detect[1:]...); err != nil {
+		return Rejected("detect command %v failed: %v %s", detect, err, bytes.TrimSpace(stderr))
+	}
+	var detection Detection
+	detection.Add(ScoreConfigured, "detect command %v succeeded", detect)
+	return detection
+}
+
+func GetCommandBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	command, ok := stringList(config["command"])
+	if !ok || len(command) == 0 {
+		logger.Errorf("command config parameter is not a list of strings as expected")
+		return nil
+	}
+	cbc := &CommandBackgroundChanger{
+		command: command,
+		formats: []string{"jpeg", "png", "jpg"},
+		timeout: commandTimeout(config),
+	}
+	if formats, ok := stringList(config["formats"]); ok {
+		cbc.formats = formats
+	}
+	if outputs, ok := stringList(config["outputs"]); ok {
+		cbc.outputs = outputs
+	}
+	return cbc
+}
+
+func init() {
+	RegisterDE("command", PrioritySpecific, CommandDetect, GetCommandBackgroundChanger)
+}
@@ -0,0 +1,88 @@
+package de
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubCommandTimeout records the commands run with a timeout
+func stubCommandTimeout(t *testing.T) *[][]string {
+	var commands [][]string
+	original := runCommandTimeout
+	t.Cleanup(func() { runCommandTimeout = original })
+	runCommandTimeout = func(timeout time.Duration, name string, args ...string) ([]byte, []byte, error) {
+		commands = append(commands, append([]string{name}, args...))
+		return nil, nil, nil
+	}
+	return &commands
+}
+
+func TestCommandApply(t *testing.T) {
+	commands := stubCommandTimeout(t)
+	config := map[string]interface{}{
+		"command": []interface{}{"setbg", "--output={output}", "{path}", "{uri}"},
+		"outputs": []interface{}{"eDP-1", "HDMI-1"},
+	}
+	cbc := GetCommandBackgroundChanger(config).(*CommandBackgroundChanger)
+	if err := cbc.Apply(Wallpaper{Picture: "/pictures/a b.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cbc.Apply(Wallpaper{Picture: "/pictures/a.jpg", Outputs: map[string]string{"HDMI-1": "/pictures/b.jpg"}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"setbg", "--output=", "/pictures/a b.jpg", "file:///pictures/a%20b.jpg"},
+		{"setbg", "--output=eDP-1", "/pictures/a.jpg", "file:///pictures/a.jpg"},
+		{"setbg", "--output=HDMI-1", "/pictures/b.jpg", "file:///pictures/b.jpg"},
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, *commands)
+	}
+
+	// Without {output} the outputs can't be told apart
+	config["command"] = []interface{}{"setbg", "{path}"}
+	if _, err := GetCommandBackgroundChanger(config).(*CommandBackgroundChanger).GetOutputs(); err != ErrOutputsUnsupported {
+		t.Errorf("Expected outputs to be unsupported, got %v", err)
+	}
+	if cbc := GetCommandBackgroundChanger(map[string]interface{}{"command": "setbg {path}"}); cbc != nil {
+		t.Errorf("Accepted a command that is not a list, %v", cbc)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	if _, err := lookPath("sleep"); err != nil {
+		t.Skip("sleep is not installed")
+	}
+	cbc := GetCommandBackgroundChanger(map[string]interface{}{
+		"command": []interface{}{"sleep", "10"},
+		"timeout": 0.1,
+	}).(*CommandBackgroundChanger)
+	start := time.Now()
+	err := cbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Command was not killed, took %v", elapsed)
+	}
+}
+
+func TestCommandDetect(t *testing.T) {
+	for detect, accepted := range map[string]bool{"true": true, "false": false} {
+		if _, err := lookPath(detect); err != nil {
+			t.Skipf("%v is not installed", detect)
+		}
+		session := NewSession(map[string]interface{}{
+			"detect":  []interface{}{detect},
+			"command": []interface{}{"setbg", "{path}"},
+		})
+		if detection := CommandDetect(session); detection.Accepted() != accepted {
+			t.Errorf("Detect command %v accepted is %v, expected %v", detect, detection.Accepted(), accepted)
+		}
+	}
+	if detection := CommandDetect(NewSession(map[string]interface{}{"detect": []interface{}{"true"}})); detection.Accepted() {
+		t.Errorf("Detected without a command configured, %v", detection)
+	}
+}
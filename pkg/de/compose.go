@@ -0,0 +1,103 @@
+package de
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+// outputsBounds is the rectangle covering every output
+func outputsBounds(outputs []Output) image.Rectangle {
+	var bounds image.Rectangle
+	for _, output := range outputs {
+		bounds = bounds.Union(image.Rect(output.X, output.Y, output.X+output.Width, output.Y+output.Height))
+	}
+	return bounds
+}
+
+// composeOutputs draws the picture of each output where the output sits in
+// the layout, for desktops that can only span one picture over all of them.
+func composeOutputs(outputs []Output, wallpaper Wallpaper) (string, error) {
+	bounds := outputsBounds(outputs)
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	hash := sha1.New()
+	for _, output := range outputs {
+		picture := wallpaper.GetPicture(output.Name)
+		fmt.Fprintf(hash, "%v:%v:%v\n", output, picture, bounds)
+		img, err := util.LoadImage(picture)
+		if err != nil {
+			return "", err
+		}
+		scaled := util.ScaleToFill(img, output.Width, output.Height)
+		target := image.Rect(output.X, output.Y, output.X+output.Width, output.Y+output.Height).Sub(bounds.Min)
+		draw.Draw(canvas, target, scaled, image.Point{}, draw.Src)
+	}
+	path := filepath.Join(workDirectory, fmt.Sprintf("composed-%v.jpg", hex.EncodeToString(hash.Sum(nil))))
+	if err := util.SaveImage(canvas, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// SpanPicture slices one picture over the whole output layout, as if it was
+// behind the monitors. Bezel is the width in pixels hidden between two
+// adjacent outputs, so lines keep straight when crossing from one to other.
+// The slices are cached, spanning the same picture again reuses them.
+func SpanPicture(picture string, outputs []Output, bezel int) (map[string]string, error) {
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("there are no outputs to span %v over", picture)
+	}
+	for _, output := range outputs {
+		if output.Width <= 0 || output.Height <= 0 {
+			return nil, fmt.Errorf("output %v has no geometry to span %v over", output.Name, picture)
+		}
+	}
+	shifted := make([]image.Rectangle, len(outputs))
+	var bounds image.Rectangle
+	for index, output := range outputs {
+		// One bezel per gap crossed, outputs side by side share their edge
+		columns, rows := make(map[int]bool), make(map[int]bool)
+		for _, other := range outputs {
+			if right := other.X + other.Width; right <= output.X {
+				columns[right] = true
+			}
+			if bottom := other.Y + other.Height; bottom <= output.Y {
+				rows[bottom] = true
+			}
+		}
+		x, y := output.X+len(columns)*bezel, output.Y+len(rows)*bezel
+		shifted[index] = image.Rect(x, y, x+output.Width, y+output.Height)
+		bounds = bounds.Union(shifted[index])
+	}
+
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%v:%v:%v\n", picture, outputs, bezel)
+	prefix := filepath.Join(workDirectory, fmt.Sprintf("span-%v", hex.EncodeToString(hash.Sum(nil))))
+
+	slices := make(map[string]string)
+	var canvas *image.RGBA
+	for index, output := range outputs {
+		slice := fmt.Sprintf("%v-%v.jpg", prefix, index)
+		slices[output.Name] = slice
+		if _, err := os.Stat(slice); err == nil {
+			continue
+		}
+		if canvas == nil {
+			img, err := util.LoadImage(picture)
+			if err != nil {
+				return nil, err
+			}
+			canvas = util.ScaleToFill(img, bounds.Dx(), bounds.Dy())
+		}
+		if err := util.SaveImage(canvas.SubImage(shifted[index].Sub(bounds.Min)), slice); err != nil {
+			return nil, err
+		}
+	}
+	return slices, nil
+}
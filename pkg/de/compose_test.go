@@ -0,0 +1,172 @@
+package de
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+// solidPicture saves a picture of a single color for the test
+func solidPicture(t *testing.T, fill color.RGBA) string {
+	picture := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			picture.Set(x, y, fill)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "picture.png")
+	if err := util.SaveImage(picture, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestComposeOutputs(t *testing.T) {
+	SetWorkDirectory(t.TempDir())
+	red := solidPicture(t, color.RGBA{R: 255, A: 255})
+	blue := solidPicture(t, color.RGBA{B: 255, A: 255})
+	// The right output sits higher, the composition starts at its top
+	outputs := []Output{
+		{Name: "left", Width: 100, Height: 100, Y: 50},
+		{Name: "right", Width: 100, Height: 100, X: 100},
+	}
+	path, err := composeOutputs(outputs, Wallpaper{Picture: red, Outputs: map[string]string{"right": blue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	composed, err := util.LoadImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := composed.Bounds().Size(); size != (image.Point{200, 150}) {
+		t.Fatalf("Composed picture is %v, expected 200x150", size)
+	}
+	for _, check := range []struct {
+		x, y   int
+		r, b   bool
+		output string
+	}{
+		{50, 100, true, false, "left"},
+		{150, 50, false, true, "right"},
+		{50, 10, false, false, "uncovered"},
+	} {
+		r, _, b, _ := composed.At(check.x, check.y).RGBA()
+		if (r>>8 > 200) != check.r || (b>>8 > 200) != check.b {
+			t.Errorf("Unexpected color %v,%v at %v,%v in the %v area", r>>8, b>>8, check.x, check.y, check.output)
+		}
+	}
+
+	again, err := composeOutputs(outputs, Wallpaper{Picture: red, Outputs: map[string]string{"right": blue}})
+	if err != nil || again != path {
+		t.Errorf("Same composition saved as %v instead of %v, %v", again, path, err)
+	}
+}
+
+func TestSpanPicture(t *testing.T) {
+	SetWorkDirectory(t.TempDir())
+	// Gradient telling the column of each pixel in red, over two outputs
+	// side by side with a bezel of 10 between them
+	picture := image.NewRGBA(image.Rect(0, 0, 210, 100))
+	for x := 0; x < 210; x++ {
+		for y := 0; y < 100; y++ {
+			picture.Set(x, y, color.RGBA{R: uint8(x), A: 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "picture.png")
+	if err := util.SaveImage(picture, path); err != nil {
+		t.Fatal(err)
+	}
+	outputs := []Output{
+		{Name: "left", Width: 100, Height: 100},
+		{Name: "right", Width: 100, Height: 100, X: 100},
+	}
+
+	slices, err := SpanPicture(path, outputs, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, origin := range map[string]int{"left": 0, "right": 110} {
+		slice, err := util.LoadImage(slices[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := slice.Bounds().Size(); size != (image.Point{100, 100}) {
+			t.Errorf("Slice of %v is %v, expected 100x100", name, size)
+		}
+		// Slices are JPEG, colors are only close to the original
+		r, _, _, _ := slice.At(slice.Bounds().Min.X+5, slice.Bounds().Min.Y+5).RGBA()
+		if x := int(r >> 8); x < origin+5-3 || x > origin+5+3 {
+			t.Errorf("Slice of %v starts around column %v, expected %v", name, x-5, origin)
+		}
+	}
+
+	again, err := SpanPicture(path, outputs, 10)
+	if err != nil || !reflect.DeepEqual(again, slices) {
+		t.Errorf("Spanning again gave %v instead of %v, %v", again, slices, err)
+	}
+}
+
+func TestSpanPictureBezels(t *testing.T) {
+	SetWorkDirectory(t.TempDir())
+	// Gradient telling the position of each pixel, red its column and green
+	// its row, over the 2x2 grid with a bezel of 10 between outputs
+	picture := image.NewRGBA(image.Rect(0, 0, 210, 210))
+	for x := 0; x < 210; x++ {
+		for y := 0; y < 210; y++ {
+			picture.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "picture.png")
+	if err := util.SaveImageFormat(picture, path, "png"); err != nil {
+		t.Fatal(err)
+	}
+	outputs := []Output{
+		{Name: "top-left", Width: 100, Height: 100},
+		{Name: "top-right", Width: 100, Height: 100, X: 100},
+		{Name: "bottom-left", Width: 100, Height: 100, Y: 100},
+		{Name: "bottom-right", Width: 100, Height: 100, X: 100, Y: 100},
+	}
+
+	slices, err := SpanPicture(path, outputs, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]image.Point{
+		"top-left":     {0, 0},
+		"top-right":    {110, 0},
+		"bottom-left":  {0, 110},
+		"bottom-right": {110, 110},
+	}
+	for name, origin := range expected {
+		slice, err := util.LoadImage(slices[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := slice.Bounds().Size(); size != (image.Point{100, 100}) {
+			t.Errorf("Slice of %v is %v, expected 100x100", name, size)
+		}
+		// Slices are JPEG, colors are only close to the original
+		r, g, _, _ := slice.At(slice.Bounds().Min.X+5, slice.Bounds().Min.Y+5).RGBA()
+		x, y := int(r>>8), int(g>>8)
+		if x < origin.X+5-3 || x > origin.X+5+3 || y < origin.Y+5-3 || y > origin.Y+5+3 {
+			t.Errorf("Slice of %v starts around %v,%v, expected %v", name, x-5, y-5, origin)
+		}
+	}
+}
+
+func TestSpanPictureWithoutGeometry(t *testing.T) {
+	SetWorkDirectory(t.TempDir())
+	for _, outputs := range [][]Output{
+		nil,
+		{{Name: "A"}, {Name: "B"}},
+		{{Name: "A", Width: 100, Height: 100}, {Name: "B", Width: 100}},
+	} {
+		if slices, err := SpanPicture("picture.png", outputs, 0); err == nil {
+			t.Errorf("Spanning over %v gave %v, expected an error", outputs, slices)
+		}
+	}
+}
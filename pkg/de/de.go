@@ -1,36 +1,238 @@
 package de
 
-import "github.com/juju/loggo"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juju/loggo"
+)
 
 var logger = loggo.GetLogger("sawyer.de")
 
+// Wallpaper is a background change. Picture is used on every output that
+// doesn't have a picture of its own in Outputs, keyed by output name.
+type Wallpaper struct {
+	Picture string
+	Outputs map[string]string
+}
+
+func (w Wallpaper) GetPicture(output string) string {
+	if picture, ok := w.Outputs[output]; ok {
+		return picture
+	}
+	return w.Picture
+}
+
+// Output is a monitor as laid out by the desktop environment
+type Output struct {
+	Name   string
+	Width  int
+	Height int
+	X      int
+	Y      int
+}
+
+// DEBackgroundChanger applies wallpapers to a desktop environment. Apply
+// blocks until the wallpaper is set, errors wrapped with Retryable are
+// worth trying again, any other means the wallpaper can't be set.
 type DEBackgroundChanger interface {
-	Set(chan string)
+	Apply(Wallpaper) error
 	GetSupportedFormats() []string
 }
 
-func GetDEBackgroundChanger(de string) DEBackgroundChanger {
-	if de != "" {
-		constructor, ok := registeredDEs[de]
-		if !ok {
-			logger.Infof("Background changer %v not found", de)
-			return nil
+// OutputBackgroundChanger is implemented by background changers that can
+// set a different picture on each output. GetOutputs fails with
+// ErrOutputsUnsupported when that depends on the system and it isn't there.
+type OutputBackgroundChanger interface {
+	DEBackgroundChanger
+	GetOutputs() ([]Output, error)
+}
+
+var ErrOutputsUnsupported = errors.New("setting a picture per output is not supported")
+
+// ClosingBackgroundChanger is implemented by backends keeping something
+// running in the background, which has to be released on shutdown.
+type ClosingBackgroundChanger interface {
+	DEBackgroundChanger
+	Close() error
+}
+
+// Close releases whatever the backend keeps running, if anything.
+func Close(backgroundChanger DEBackgroundChanger) error {
+	if closing, ok := backgroundChanger.(ClosingBackgroundChanger); ok {
+		return closing.Close()
+	}
+	return nil
+}
+
+// runCommand and getenv are the only way backends reach the outside world,
+// so they can be stubbed out when testing them. Commands are killed after
+// defaultCommandTimeout, so one hanging doesn't block shutting down.
+var runCommand = func(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCommandTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, Retryable(fmt.Errorf("%v timed out after %v", name, defaultCommandTimeout))
+	}
+	return output, err
+}
+
+var startCommand = func(name string, args ...string) (*exec.Cmd, error) {
+	command := exec.Command(name, args...)
+	return command, command.Start()
+}
+
+var getenv = os.Getenv
+
+var lookPath = exec.LookPath
+
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+var workDirectory = filepath.Join(os.TempDir(), "sawyer")
+
+// SetWorkDirectory is where background changers can write the pictures
+// they generate from the ones they are given.
+func SetWorkDirectory(directory string) {
+	workDirectory = directory
+}
+
+// inWorkDirectory tells the pictures generated by background changers,
+// which are removed once they aren't shown anymore.
+func inWorkDirectory(path string) bool {
+	relative, err := filepath.Rel(workDirectory, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// GetDEBackgroundChanger returns the background changer named de, or the
+// detected one when empty, set up with the given config.
+func GetDEBackgroundChanger(de string, config map[string]interface{}) (DEBackgroundChanger, error) {
+	if de == "" {
+		detected, err := DetectDE(config)
+		if err != nil {
+			return nil, err
+		}
+		de = detected
+	} else {
+		logger.Infof("Desktop environment %v forced by configuration", de)
+	}
+	registration, ok := registeredDEs[de]
+	if !ok {
+		return nil, fmt.Errorf("desktop environment %v not found, available are %v", de, strings.Join(GetRegisteredDEs(), ", "))
+	}
+	backgroundChanger := registration.constructor(config)
+	if backgroundChanger == nil {
+		return nil, fmt.Errorf("desktop environment %v is not usable in this system", de)
+	}
+	return backgroundChanger, nil
+}
+
+// DetectDE returns the desktop environment whose detection scores highest
+// for the running session. Ties are broken by the priority it was
+// registered with and then by name, so the same session always gets the
+// same one. It's an error when that one can't be set up.
+func DetectDE(config map[string]interface{}) (string, error) {
+	session := NewSession(config)
+	type candidate struct {
+		registration registeredDE
+		detection    Detection
+	}
+	var candidates []candidate
+	for _, id := range GetRegisteredDEs() {
+		registration := registeredDEs[id]
+		detection := registration.detect(session)
+		if !detection.Accepted() {
+			logger.Infof("Desktop environment %v rejected: %v", id, detection)
+			continue
 		}
-		return constructor()
+		logger.Infof("Desktop environment %v accepted with score %v: %v", id, detection.Score, detection)
+		candidates = append(candidates, candidate{registration, detection})
 	}
-	for id, constructor := range registeredDEs {
-		de := constructor()
-		if de != nil {
-			logger.Infof("Desktop environment %v matched", id)
-			return de
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].detection.Score != candidates[j].detection.Score {
+			return candidates[i].detection.Score > candidates[j].detection.Score
 		}
+		return candidates[i].registration.priority > candidates[j].registration.priority
+	})
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no supported desktop environment detected, set one of %v explicitly", strings.Join(GetRegisteredDEs(), ", "))
 	}
-	return nil
+	// Falling back to the next one would silently change the background of
+	// something else than the session is running
+	matched := candidates[0].registration
+	if matched.constructor(config) == nil {
+		return "", fmt.Errorf("desktop environment %v was detected but could not be set up, check its options or set one of %v explicitly", matched.name, strings.Join(GetRegisteredDEs(), ", "))
+	}
+	logger.Infof("Desktop environment %v matched", matched.name)
+	return matched.name, nil
 }
 
-var registeredDEs = make(map[string]func() DEBackgroundChanger)
+// Registration priorities, used to break ties between detections
+const (
+	PriorityGeneric  = 10
+	PriorityDesktop  = 20
+	PrioritySpecific = 30
+)
 
-func RegisterDE(de string, constructor func() DEBackgroundChanger) {
+type registeredDE struct {
+	name        string
+	priority    int
+	detect      func(*Session) Detection
+	constructor func(map[string]interface{}) DEBackgroundChanger
+}
+
+var registeredDEs = make(map[string]registeredDE)
+
+// RegisterDE makes a background changer available. Priority only matters
+// when two detections score the same, more specific backends should use a
+// higher one than the generic ones they overlap with.
+func RegisterDE(de string, priority int, detect func(*Session) Detection, constructor func(map[string]interface{}) DEBackgroundChanger) {
 	logger.Tracef("Registering desktop environment %v", de)
-	registeredDEs[de] = constructor
+	registeredDEs[de] = registeredDE{
+		name:        de,
+		priority:    priority,
+		detect:      detect,
+		constructor: constructor,
+	}
+}
+
+func GetRegisteredDEs() []string {
+	names := make([]string, 0, len(registeredDEs))
+	for name := range registeredDEs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var registeredLockScreens = make(map[string]func(map[string]interface{}) DEBackgroundChanger)
+
+// RegisterLockScreen makes the lock screen of a desktop environment
+// available, de is the name the desktop environment is registered with.
+func RegisterLockScreen(de string, constructor func(map[string]interface{}) DEBackgroundChanger) {
+	logger.Tracef("Registering lock screen for %v", de)
+	registeredLockScreens[de] = constructor
+}
+
+// GetLockScreenBackgroundChanger returns the lock screen background changer
+// of the desktop environment named de.
+func GetLockScreenBackgroundChanger(de string, config map[string]interface{}) (DEBackgroundChanger, error) {
+	constructor, ok := registeredLockScreens[de]
+	if !ok {
+		return nil, fmt.Errorf("lock screen of %v is not supported", de)
+	}
+	backgroundChanger := constructor(config)
+	if backgroundChanger == nil {
+		return nil, fmt.Errorf("lock screen of %v is not usable in this system", de)
+	}
+	return backgroundChanger, nil
 }
@@ -0,0 +1,269 @@
+package de
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubSession replaces the environment and processes sessions are taken
+// from
+func stubSession(t *testing.T, environment map[string]string, processes ...string) {
+	originalGetenv, originalListProcesses := getenv, listProcesses
+	t.Cleanup(func() { getenv, listProcesses = originalGetenv, originalListProcesses })
+	getenv = func(name string) string { return environment[name] }
+	listProcesses = func() []string { return processes }
+}
+
+// socketInfo stands for the information of a socket file
+type socketInfo struct {
+	os.FileInfo
+}
+
+func (si socketInfo) Mode() os.FileMode {
+	return os.ModeSocket
+}
+
+// stubSockets makes the paths given the only sockets there are
+func stubSockets(t *testing.T, paths ...string) {
+	original := statPath
+	t.Cleanup(func() { statPath = original })
+	statPath = func(path string) (os.FileInfo, error) {
+		for _, socket := range paths {
+			if path == socket {
+				return socketInfo{}, nil
+			}
+		}
+		return nil, os.ErrNotExist
+	}
+}
+
+// stubRunner records the commands run, answering them with respond
+func stubRunner(t *testing.T, respond func(command []string) ([]byte, error)) *[][]string {
+	var commands [][]string
+	original := runCommand
+	t.Cleanup(func() { runCommand = original })
+	runCommand = func(name string, args ...string) ([]byte, error) {
+		command := append([]string{name}, args...)
+		commands = append(commands, command)
+		return respond(command)
+	}
+	return &commands
+}
+
+// stubCommands records the commands run, failing with err
+func stubCommands(t *testing.T, err error) *[][]string {
+	return stubRunner(t, func(command []string) ([]byte, error) {
+		if err != nil {
+			return []byte("failed"), err
+		}
+		return nil, nil
+	})
+}
+
+// stubInstalled makes lookPath find only the programs given
+func stubInstalled(t *testing.T, programs ...string) {
+	original := lookPath
+	t.Cleanup(func() { lookPath = original })
+	lookPath = func(file string) (string, error) {
+		for _, program := range programs {
+			if file == program {
+				return "/usr/bin/" + file, nil
+			}
+		}
+		return "", exec.ErrNotFound
+	}
+}
+
+// fakeBackgroundChanger is what desktop environments registered by tests
+// set up, named after them. Applying fails with errs in turn.
+type fakeBackgroundChanger struct {
+	name    string
+	errs    []error
+	applied []Wallpaper
+}
+
+func (fbc *fakeBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	fbc.applied = append(fbc.applied, wallpaper)
+	if len(fbc.errs) == 0 {
+		return nil
+	}
+	err := fbc.errs[0]
+	fbc.errs = fbc.errs[1:]
+	return err
+}
+
+func (fbc *fakeBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+// registerFake registers a desktop environment detected with score, which
+// is only there for the test. An unusable one can't be set up.
+func registerFake(t *testing.T, name string, priority, score int, usable bool) {
+	t.Cleanup(func() { delete(registeredDEs, name) })
+	RegisterDE(name, priority, func(session *Session) Detection {
+		var detection Detection
+		detection.Add(score, "%v always scores %v", name, score)
+		return detection
+	}, func(map[string]interface{}) DEBackgroundChanger {
+		if !usable {
+			return nil
+		}
+		return &fakeBackgroundChanger{name: name}
+	})
+}
+
+func TestDetectDE(t *testing.T) {
+	type fake struct {
+		name            string
+		priority, score int
+		usable          bool
+	}
+	tests := []struct {
+		fakes    []fake
+		expected string
+		err      string
+	}{
+		{[]fake{{"test-low", PrioritySpecific, 5, true}, {"test-high", PriorityGeneric, 30, true}}, "test-high", ""},
+		// Equal scores go to the highest priority, then to the first name
+		{[]fake{{"test-generic", PriorityGeneric, 20, true}, {"test-specific", PrioritySpecific, 20, true}}, "test-specific", ""},
+		{[]fake{{"test-b", PriorityDesktop, 20, true}, {"test-a", PriorityDesktop, 20, true}, {"test-generic", PriorityGeneric, 20, true}}, "test-a", ""},
+		// The one detected not being usable doesn't fall back to others
+		{[]fake{{"test-broken", PriorityDesktop, 30, false}, {"test-other", PriorityDesktop, 20, true}}, "", "test-broken"},
+		{[]fake{{"test-a", PriorityDesktop, 20, false}, {"test-b", PriorityDesktop, 20, true}}, "", "test-a"},
+		{[]fake{{"test-rejected", PriorityDesktop, 0, true}}, "", "no supported desktop environment"},
+	}
+	for _, test := range tests {
+		// Only the fakes are registered, so real detections don't interfere
+		original := registeredDEs
+		registeredDEs = make(map[string]registeredDE)
+		stubSession(t, nil)
+		for _, fake := range test.fakes {
+			registerFake(t, fake.name, fake.priority, fake.score, fake.usable)
+		}
+		// Several attempts, as the registrations are iterated from a map
+		for attempt := 0; attempt < 10; attempt++ {
+			detected, err := DetectDE(nil)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("%v expected an error about %v, got %v %v", test.fakes, test.err, detected, err)
+				}
+			} else if err != nil || detected != test.expected {
+				t.Errorf("%v expected %v, got %v %v", test.fakes, test.expected, detected, err)
+			}
+		}
+		registeredDEs = original
+	}
+}
+
+func TestDetectSessions(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Desktop environments are only detected on linux")
+	}
+	stubInstalled(t, "feh", "swaybg")
+	stubCommands(t, nil)
+	tests := []struct {
+		environment map[string]string
+		processes   []string
+		sockets     []string
+		expected    string
+	}{
+		{map[string]string{"XDG_CURRENT_DESKTOP": "KDE", "KDE_FULL_SESSION": "true"}, []string{"plasmashell"}, nil, "kde-plasma"},
+		{map[string]string{"DESKTOP_SESSION": "plasma"}, nil, nil, "kde-plasma"},
+		{map[string]string{"XDG_CURRENT_DESKTOP": "ubuntu:GNOME", "DISPLAY": ":0"}, []string{"gnome-shell"}, nil, "gnome-shell"},
+		// Budgie ahead of GNOME in XDG_CURRENT_DESKTOP wins over it
+		{map[string]string{"XDG_CURRENT_DESKTOP": "Budgie:GNOME"}, []string{"budgie-panel", "gnome-shell"}, nil, "budgie"},
+		{map[string]string{"XDG_CURRENT_DESKTOP": "X-Cinnamon"}, nil, nil, "cinnamon"},
+		{map[string]string{"XDG_CURRENT_DESKTOP": "MATE"}, nil, nil, "mate"},
+		{map[string]string{"XDG_CURRENT_DESKTOP": "XFCE", "DISPLAY": ":0"}, []string{"xfdesktop"}, nil, "xfce"},
+		{map[string]string{"XDG_CURRENT_DESKTOP": "LXQt"}, nil, nil, "lxqt"},
+		{map[string]string{"XDG_CURRENT_DESKTOP": "i3", "DISPLAY": ":0"}, nil, nil, "x11"},
+		{map[string]string{"DISPLAY": ":1"}, []string{"openbox"}, nil, "x11"},
+		{map[string]string{"WAYLAND_DISPLAY": "wayland-1", "SWAYSOCK": "/run/sway.sock"}, []string{"sway", "swaybg"}, []string{"/run/sway.sock"}, "sway"},
+		{map[string]string{"WAYLAND_DISPLAY": "wayland-1", "XDG_CURRENT_DESKTOP": "Hyprland", "HYPRLAND_INSTANCE_SIGNATURE": "abc"}, []string{"Hyprland", "hyprpaper"}, nil, "hyprpaper"},
+		{map[string]string{"WAYLAND_DISPLAY": "wayland-1", "XDG_CURRENT_DESKTOP": "river"}, nil, nil, "swaybg"},
+		// Equal scores go to the highest priority, then to the first name
+		{nil, []string{"sway", "plasmashell"}, nil, "sway"},
+		{nil, []string{"xfdesktop", "plasmashell"}, nil, "kde-plasma"},
+		{nil, nil, nil, ""},
+	}
+	for _, test := range tests {
+		stubSession(t, test.environment, test.processes...)
+		stubSockets(t, test.sockets...)
+		backgroundChanger, err := GetDEBackgroundChanger("", nil)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%v %v detected as %T", test.environment, test.processes, backgroundChanger)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v %v not detected. %v", test.environment, test.processes, err)
+			continue
+		}
+		// Printed, as the x11 one holds a func DeepEqual can't compare
+		if expected := registeredDEs[test.expected].constructor(nil); fmt.Sprintf("%#v", backgroundChanger) != fmt.Sprintf("%#v", expected) {
+			t.Errorf("%v %v detected as %#v, expected %v", test.environment, test.processes, backgroundChanger, test.expected)
+		}
+	}
+}
+
+func TestGetDEBackgroundChangerForced(t *testing.T) {
+	stubSession(t, map[string]string{"XDG_CURRENT_DESKTOP": "GNOME"})
+	backgroundChanger, err := GetDEBackgroundChanger("kde-plasma", nil)
+	if _, ok := backgroundChanger.(*KdePlasmaBackgroundChanger); !ok || err != nil {
+		t.Errorf("kde-plasma not set up when forced, %v", err)
+	}
+	if _, err := GetDEBackgroundChanger("unknown", nil); err == nil || !strings.Contains(err.Error(), "kde-plasma") {
+		t.Errorf("Expected the available ones to be listed, got %v", err)
+	}
+}
+
+func TestGetLockScreenBackgroundChanger(t *testing.T) {
+	commands := stubCommands(t, nil)
+	changer, err := GetLockScreenBackgroundChanger("gnome-shell", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changer.(*GSettingsBackgroundChanger).Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"gsettings", "set", "org.gnome.desktop.screensaver", "picture-uri", "file:///pictures/a.jpg"}
+	if len(*commands) != 1 || !reflect.DeepEqual((*commands)[0], expected) {
+		t.Errorf("Expected %q, got %q", expected, *commands)
+	}
+
+	if changer, err := GetLockScreenBackgroundChanger("sway", nil); err == nil {
+		t.Errorf("Expected sway to have no lock screen, got %v", changer)
+	}
+	stubInstalled(t)
+	if changer, err := GetLockScreenBackgroundChanger("kde-plasma", nil); err == nil {
+		t.Errorf("Expected the KDE lock screen to be unusable without kwriteconfig, got %v", changer)
+	}
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not available")
+	}
+	original := defaultCommandTimeout
+	t.Cleanup(func() { defaultCommandTimeout = original })
+	defaultCommandTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := runCommand("sleep", "10")
+	if !IsRetryable(err) {
+		t.Errorf("Expected a retryable timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Hanging command kept running for %v", elapsed)
+	}
+	if _, err := runCommand("sleep", "0"); err != nil {
+		t.Errorf("Command failed within the timeout. %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package de
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+const (
+	exportModeSymlink = "symlink"
+	exportModeCopy    = "copy"
+)
+
+// ExportBackgroundChanger keeps a fixed path pointing at the current
+// picture for tools reading their background from a file, like greeters
+// or terminals. The path is a symlink or a copy, which is re-encoded when
+// a format or a size is configured, a width or height alone keeping the
+// aspect ratio. Pictures generated in the work directory, like spanned
+// slices, are copied even in symlink mode as they are removed once
+// replaced. It's replaced atomically, so readers never see it missing or
+// half written.
+type ExportBackgroundChanger struct {
+	path   string
+	mode   string
+	output string
+	format string
+	width  int
+	height int
+}
+
+func (ebc *ExportBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	picture := wallpaper.GetPicture(ebc.output)
+	if err := os.MkdirAll(filepath.Dir(ebc.path), 0755); err != nil {
+		return err
+	}
+	switch {
+	case ebc.reencodes():
+		return ebc.reencode(picture)
+	case ebc.mode == exportModeCopy || inWorkDirectory(picture):
+		content, err := ioutil.ReadFile(picture)
+		if err != nil {
+			return err
+		}
+		return util.WriteFileAtomic(ebc.path, content)
+	default:
+		return symlinkAtomic(picture, ebc.path)
+	}
+}
+
+func (ebc *ExportBackgroundChanger) reencodes() bool {
+	return ebc.format != "" || ebc.width > 0 || ebc.height > 0
+}
+
+// size is the one configured, the side missing keeping the aspect ratio
+func (ebc *ExportBackgroundChanger) size(bounds image.Rectangle) (int, int) {
+	width, height := ebc.width, ebc.height
+	if width <= 0 {
+		width = (height*bounds.Dx() + bounds.Dy()/2) / bounds.Dy()
+	}
+	if height <= 0 {
+		height = (width*bounds.Dy() + bounds.Dx()/2) / bounds.Dx()
+	}
+	return width, height
+}
+
+func (ebc *ExportBackgroundChanger) reencode(picture string) error {
+	img, err := util.LoadImage(picture)
+	if err != nil {
+		return err
+	}
+	if ebc.width > 0 || ebc.height > 0 {
+		width, height := ebc.size(img.Bounds())
+		img = util.ScaleToFill(img, width, height)
+	}
+	format := ebc.format
+	if format == "" {
+		format = filepath.Ext(ebc.path)
+	}
+	if format == "" {
+		format = filepath.Ext(picture)
+	}
+	return util.SaveImageFormat(img, ebc.path, format)
+}
+
+func (ebc *ExportBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+func (ebc *ExportBackgroundChanger) String() string {
+	return fmt.Sprintf("export-%v", ebc.path)
+}
+
+// symlinkAtomic points link at target replacing whatever link was, so it
+// never goes missing for whoever follows it.
+func symlinkAtomic(target, link string) error {
+	temporary := filepath.Join(filepath.Dir(link), fmt.Sprintf(".tmp-%v-%v", filepath.Base(link), os.Getpid()))
+	os.Remove(temporary)
+	if err := os.Symlink(target, temporary); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary, link); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return nil
+}
+
+func ExportDetect(session *Session) Detection {
+	return Rejected("only used when selected in the configuration")
+}
+
+func GetExportBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	path, ok := config["path"].(string)
+	if !ok || path == "" {
+		logger.Errorf("path config parameter is not a string as expected")
+		return nil
+	}
+	ebc := &ExportBackgroundChanger{path: path, mode: exportModeSymlink}
+	if mode, ok := config["mode"].(string); ok {
+		if mode != exportModeSymlink && mode != exportModeCopy {
+			logger.Errorf("Export mode %q is not %v nor %v", mode, exportModeSymlink, exportModeCopy)
+			return nil
+		}
+		ebc.mode = mode
+	}
+	ebc.output, _ = config["output"].(string)
+	if format, ok := config["format"].(string); ok {
+		ebc.format = strings.ToLower(strings.TrimPrefix(format, "."))
+	}
+	width, _ := config["width"].(float64)
+	height, _ := config["height"].(float64)
+	if width < 0 || height < 0 {
+		logger.Errorf("Export width %v and height %v can't be negative", width, height)
+		return nil
+	}
+	ebc.width, ebc.height = int(width), int(height)
+	return ebc
+}
+
+func init() {
+	RegisterDE("export", PriorityGeneric, ExportDetect, GetExportBackgroundChanger)
+}
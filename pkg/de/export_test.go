@@ -0,0 +1,136 @@
+package de
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+func TestExportModes(t *testing.T) {
+	directory := t.TempDir()
+	first, second := filepath.Join(directory, "a.png"), filepath.Join(directory, "b.png")
+	for _, picture := range []string{first, second} {
+		if err := util.SaveImage(image.NewRGBA(image.Rect(0, 0, 20, 10)), picture); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	link := filepath.Join(directory, "exported", "link")
+	symlink := GetExportBackgroundChanger(map[string]interface{}{"path": link})
+	copied := filepath.Join(directory, "copied.png")
+	copy := GetExportBackgroundChanger(map[string]interface{}{"path": copied, "mode": "copy"})
+	for _, picture := range []string{first, second} {
+		if err := symlink.Apply(Wallpaper{Picture: picture}); err != nil {
+			t.Fatal(err)
+		}
+		if target, err := os.Readlink(link); err != nil || target != picture {
+			t.Errorf("Export points at %v instead of %v. %v", target, picture, err)
+		}
+		if err := copy.Apply(Wallpaper{Picture: picture}); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Lstat(copied); err != nil || !info.Mode().IsRegular() {
+			t.Errorf("Copy is not a regular file, %v %v", info, err)
+		}
+	}
+	if files, _ := ioutil.ReadDir(filepath.Dir(link)); len(files) != 1 {
+		t.Errorf("Temporary files left behind, %v", files)
+	}
+	if changer := GetExportBackgroundChanger(map[string]interface{}{"path": link, "mode": "hardlink"}); changer != nil {
+		t.Errorf("Unknown mode accepted")
+	}
+}
+
+func TestExportCopiesWorkFiles(t *testing.T) {
+	SetWorkDirectory(t.TempDir())
+	slice := filepath.Join(workDirectory, "span-0.jpg")
+	if err := util.SaveImage(image.NewRGBA(image.Rect(0, 0, 20, 10)), slice); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err := GetExportBackgroundChanger(map[string]interface{}{"path": link}).Apply(Wallpaper{Picture: slice}); err != nil {
+		t.Fatal(err)
+	}
+	// The slice is removed once another picture is spanned
+	if err := os.Remove(slice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.LoadImage(link); err != nil {
+		t.Errorf("Export broken once its picture was removed. %v", err)
+	}
+
+	for path, expected := range map[string]bool{
+		filepath.Join(workDirectory, "a", "b.jpg"):  true,
+		filepath.Join(workDirectory, "..", "b.jpg"): false,
+		workDirectory + "-other/b.jpg":              false,
+	} {
+		if inWorkDirectory(path) != expected {
+			t.Errorf("%v in the work directory is not %v", path, expected)
+		}
+	}
+}
+
+func TestExportReencodes(t *testing.T) {
+	directory := t.TempDir()
+	picture := filepath.Join(directory, "picture.jpg")
+	if err := util.SaveImage(image.NewRGBA(image.Rect(0, 0, 200, 100)), picture); err != nil {
+		t.Fatal(err)
+	}
+	exported := filepath.Join(directory, "background")
+	changer := GetExportBackgroundChanger(map[string]interface{}{"path": exported, "format": ".PNG", "width": 100.0, "height": 50.0})
+	if err := changer.Apply(Wallpaper{Picture: picture, Outputs: map[string]string{"HDMI-1": "/pictures/other.jpg"}}); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatalf("Export is not a PNG. %v", err)
+	}
+	if size := img.Bounds().Size(); size != (image.Point{100, 50}) {
+		t.Errorf("Export is %v, expected 100x50", size)
+	}
+}
+
+func TestExportKeepsAspectRatio(t *testing.T) {
+	directory := t.TempDir()
+	picture := filepath.Join(directory, "picture.png")
+	if err := util.SaveImageFormat(image.NewRGBA(image.Rect(0, 0, 200, 100)), picture, "png"); err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []map[string]interface{}{
+		{"width": 100.0, "height": 50.0},
+		{"width": 100.0},
+		{"height": 50.0},
+	} {
+		config := map[string]interface{}{"path": filepath.Join(directory, "exported.png")}
+		for key, value := range size {
+			config[key] = value
+		}
+		changer := GetExportBackgroundChanger(config)
+		if err := changer.Apply(Wallpaper{Picture: picture}); err != nil {
+			t.Fatal(err)
+		}
+		exported, err := util.LoadImage(config["path"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bounds := exported.Bounds().Size(); bounds != (image.Point{100, 50}) {
+			t.Errorf("Exporting with %v gave %v, expected 100x50", size, bounds)
+		}
+	}
+}
+
+func TestExportRejectsNegativeSize(t *testing.T) {
+	if changer := GetExportBackgroundChanger(map[string]interface{}{"path": "exported.png", "width": -1.0}); changer != nil {
+		t.Errorf("Negative width was accepted")
+	}
+}
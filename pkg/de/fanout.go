@@ -0,0 +1,276 @@
+package de
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+// Formats pictures can be converted to and from when a target doesn't
+// support the format of a picture.
+var convertibleFormats = []string{"jpeg", "png", "jpg"}
+
+// Target is a background changer wallpapers are fanned out to. Formats
+// narrows the formats the backend supports, pictures in any other format
+// are converted before being applied. An optional target failing doesn't
+// make the wallpaper fail to apply. The wallpapers are laid out on the
+// outputs of the primary target.
+type Target struct {
+	Name              string
+	BackgroundChanger DEBackgroundChanger
+	Formats           []string
+	Optional          bool
+	Primary           bool
+
+	// conversions of the wallpaper last applied, keyed by picture
+	converted map[string]string
+
+	mutex sync.Mutex
+	stats TargetStats
+}
+
+// TargetStats tells how applying wallpapers to a target went so far
+type TargetStats struct {
+	Applied     int
+	Failed      int
+	LastLatency time.Duration
+	// TotalLatency adds up the latency of every wallpaper applied or not
+	TotalLatency time.Duration
+}
+
+func (ts TargetStats) AverageLatency() time.Duration {
+	if ts.Applied+ts.Failed == 0 {
+		return 0
+	}
+	return ts.TotalLatency / time.Duration(ts.Applied+ts.Failed)
+}
+
+func (ts TargetStats) String() string {
+	return fmt.Sprintf("%v applied, %v failed, latency %v last and %v average", ts.Applied, ts.Failed, ts.LastLatency, ts.AverageLatency())
+}
+
+func (t *Target) Stats() TargetStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.stats
+}
+
+func (t *Target) record(result Result) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if result.Err == nil {
+		t.stats.Applied++
+	} else {
+		t.stats.Failed++
+	}
+	t.stats.LastLatency = result.Latency
+	t.stats.TotalLatency += result.Latency
+}
+
+func (t *Target) GetSupportedFormats() []string {
+	if len(t.Formats) != 0 {
+		return t.Formats
+	}
+	return t.BackgroundChanger.GetSupportedFormats()
+}
+
+func (t *Target) String() string {
+	return t.Name
+}
+
+func (t *Target) apply(ctx context.Context, wallpaper Wallpaper, retries int, delay time.Duration) Result {
+	start := time.Now()
+	negotiated, converted, err := t.negotiate(wallpaper)
+	if err != nil {
+		result := Result{Target: t.Name, Wallpaper: wallpaper, Err: err, Latency: time.Since(start)}
+		t.record(result)
+		return result
+	}
+	result := Apply(ctx, t.BackgroundChanger, negotiated, retries, delay)
+	result.Target = t.Name
+	result.Latency = time.Since(start)
+	if result.Err == nil {
+		removeConversions(t.converted, converted)
+		t.converted = converted
+	} else {
+		removeConversions(converted, t.converted)
+	}
+	t.record(result)
+	return result
+}
+
+// negotiate converts every picture of the wallpaper the target doesn't
+// support, returning the wallpaper to apply and the conversions made.
+func (t *Target) negotiate(wallpaper Wallpaper) (Wallpaper, map[string]string, error) {
+	formats := t.GetSupportedFormats()
+	converted := make(map[string]string)
+	convert := func(picture string) (string, error) {
+		if picture == "" || CheckFormats(Wallpaper{Picture: picture}, formats) == nil {
+			return picture, nil
+		}
+		if conversion, ok := converted[picture]; ok {
+			return conversion, nil
+		}
+		conversion, err := convertPicture(t.Name, picture, formats)
+		if err != nil {
+			return "", err
+		}
+		converted[picture] = conversion
+		return conversion, nil
+	}
+
+	negotiated := Wallpaper{}
+	var err error
+	if negotiated.Picture, err = convert(wallpaper.Picture); err != nil {
+		return wallpaper, converted, err
+	}
+	if wallpaper.Outputs != nil {
+		negotiated.Outputs = make(map[string]string)
+		for output, picture := range wallpaper.Outputs {
+			if negotiated.Outputs[output], err = convert(picture); err != nil {
+				return wallpaper, converted, err
+			}
+		}
+	}
+	return negotiated, converted, nil
+}
+
+// removeConversions removes the stale conversions that are not kept
+func removeConversions(stale, keep map[string]string) {
+	for picture, conversion := range stale {
+		if keep[picture] == conversion {
+			continue
+		}
+		if err := os.Remove(conversion); err != nil && !os.IsNotExist(err) {
+			logger.Debugf("Failed to remove conversion %v. %v", conversion, err)
+		}
+	}
+}
+
+// convertPicture re-encodes picture in the first of formats that can be
+// encoded, the result is kept in the work directory.
+func convertPicture(target, picture string, formats []string) (string, error) {
+	format := ""
+	for _, supported := range formats {
+		if isOneOf(supported, convertibleFormats) {
+			format = supported
+			break
+		}
+	}
+	if format == "" || !isOneOf(strings.TrimPrefix(strings.ToLower(filepath.Ext(picture)), "."), convertibleFormats) {
+		return "", fmt.Errorf("%w: %v can't be converted to any of %v", ErrUnsupportedFormat, picture, formats)
+	}
+	img, err := util.LoadImage(picture)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(target + "\x00" + picture))
+	conversion := filepath.Join(workDirectory, fmt.Sprintf("convert-%v.%v", hex.EncodeToString(sum[:]), format))
+	if err := util.SaveImage(img, conversion); err != nil {
+		return "", err
+	}
+	logger.Debugf("Converted %v to %v for %v", picture, conversion, target)
+	return conversion, nil
+}
+
+// FanOut applies every wallpaper to all its targets at once, so a slow or
+// failing target doesn't hold back the others.
+type FanOut struct {
+	Targets []*Target
+}
+
+func NewFanOut(targets ...*Target) *FanOut {
+	return &FanOut{Targets: targets}
+}
+
+// Primary is the background changer of the primary target, whose outputs
+// lay the wallpapers out, or nil when there is none.
+func (fo *FanOut) Primary() DEBackgroundChanger {
+	for _, target := range fo.Targets {
+		if target.Primary {
+			return target.BackgroundChanger
+		}
+	}
+	return nil
+}
+
+// Formats are the picture formats wallpapers are picked from: the ones the
+// primary target can use natively or converted. The other targets convert
+// the pictures they can't use, so without a primary target they are the
+// formats pictures can be converted from.
+func (fo *FanOut) Formats() []string {
+	var formats []string
+	for _, target := range fo.Targets {
+		if target.Primary {
+			formats = append(formats, target.GetSupportedFormats()...)
+			break
+		}
+	}
+	convertible := len(formats) == 0
+	for _, format := range formats {
+		convertible = convertible || isOneOf(format, convertibleFormats)
+	}
+	if convertible {
+		for _, format := range convertibleFormats {
+			if !isOneOf(format, formats) {
+				formats = append(formats, format)
+			}
+		}
+	}
+	return formats
+}
+
+// Apply applies the wallpaper to every target, returning their results in
+// the same order.
+func (fo *FanOut) Apply(ctx context.Context, wallpaper Wallpaper, retries int, delay time.Duration) []Result {
+	results := make([]Result, len(fo.Targets))
+	var applying sync.WaitGroup
+	for index, target := range fo.Targets {
+		applying.Add(1)
+		go func(index int, target *Target) {
+			defer applying.Done()
+			results[index] = target.apply(ctx, wallpaper, retries, delay)
+		}(index, target)
+	}
+	applying.Wait()
+	return results
+}
+
+// Applied tells whether the wallpaper the results are of was applied: when
+// every target that isn't optional applied it, or any of them when all are
+// optional.
+func (fo *FanOut) Applied(results []Result) bool {
+	required, anyApplied := false, false
+	for index, result := range results {
+		if result.Err == nil {
+			anyApplied = true
+		} else if index < len(fo.Targets) && !fo.Targets[index].Optional {
+			return false
+		}
+		if index < len(fo.Targets) && !fo.Targets[index].Optional {
+			required = true
+		}
+	}
+	return required || anyApplied
+}
+
+func (fo *FanOut) Close() error {
+	var failed []string
+	for _, target := range fo.Targets {
+		if err := Close(target.BackgroundChanger); err != nil {
+			failed = append(failed, fmt.Sprintf("%v: %v", target, err))
+		}
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("failed to close %v", strings.Join(failed, ", "))
+	}
+	return nil
+}
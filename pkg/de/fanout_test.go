@@ -0,0 +1,139 @@
+package de
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+func TestTargetConvertsPictures(t *testing.T) {
+	SetWorkDirectory(t.TempDir())
+	directory := t.TempDir()
+	first, second := filepath.Join(directory, "a.png"), filepath.Join(directory, "b.jpg")
+	for _, picture := range []string{first, second} {
+		if err := util.SaveImage(image.NewRGBA(image.Rect(0, 0, 20, 10)), picture); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fbc := &fakeBackgroundChanger{}
+	target := &Target{Name: "jpg-only", BackgroundChanger: fbc, Formats: []string{"jpg"}}
+
+	result := target.apply(context.Background(), Wallpaper{Picture: first, Outputs: map[string]string{"HDMI-1": second}}, 0, 0)
+	if result.Err != nil || result.Target != "jpg-only" {
+		t.Fatalf("Expected the png to be converted, got %v", result)
+	}
+	applied := fbc.applied[0]
+	conversion := applied.Picture
+	if filepath.Ext(conversion) != ".jpg" || applied.Outputs["HDMI-1"] != second {
+		t.Errorf("Expected only the png converted, got %v", applied)
+	}
+	if _, err := util.LoadImage(conversion); err != nil {
+		t.Errorf("Conversion can't be read. %v", err)
+	}
+
+	// Conversions are removed once the next wallpaper is applied
+	if result := target.apply(context.Background(), Wallpaper{Picture: second}, 0, 0); result.Err != nil {
+		t.Fatal(result)
+	}
+	if _, err := os.Stat(conversion); !os.IsNotExist(err) {
+		t.Errorf("Conversion %v not removed", conversion)
+	}
+
+	unconvertible := &Target{Name: "webp-only", BackgroundChanger: fbc, Formats: []string{"webp"}}
+	if result := unconvertible.apply(context.Background(), Wallpaper{Picture: first}, 0, 0); result.Status() != StatusUnsupported {
+		t.Errorf("Expected the png to be unsupported, got %v", result)
+	}
+}
+
+func TestFanOutApply(t *testing.T) {
+	working, failing := &fakeBackgroundChanger{}, &fakeBackgroundChanger{errs: []error{errors.New("broken")}}
+	fanOut := NewFanOut(&Target{Name: "working", BackgroundChanger: working}, &Target{Name: "failing", BackgroundChanger: failing})
+	results := fanOut.Apply(context.Background(), Wallpaper{Picture: "/pictures/a.jpg"}, 0, 0)
+	if len(results) != 2 || results[0].Target != "working" || results[0].Err != nil || results[1].Target != "failing" || results[1].Err == nil {
+		t.Errorf("Expected results in target order, got %v", results)
+	}
+	if len(working.applied) != 1 {
+		t.Errorf("Failing target held back the others")
+	}
+}
+
+func TestFanOutPrimary(t *testing.T) {
+	export, desktop := &fakeBackgroundChanger{name: "export"}, &fakeBackgroundChanger{name: "desktop"}
+	fanOut := NewFanOut(&Target{Name: "export", BackgroundChanger: export, Formats: []string{"webp"}}, &Target{Name: "desktop", BackgroundChanger: desktop})
+	if fanOut.Primary() != nil {
+		t.Errorf("Primary without any target marked as such")
+	}
+	// Without a primary target pictures are picked in the formats others
+	// can convert from
+	if formats := fanOut.Formats(); !reflect.DeepEqual(formats, []string{"jpeg", "png", "jpg"}) {
+		t.Errorf("Unexpected formats %v", formats)
+	}
+
+	fanOut.Targets[1].Primary = true
+	if fanOut.Primary() != desktop {
+		t.Errorf("Primary is not the target marked as such")
+	}
+	// Other targets don't widen the formats pictures are picked from
+	fanOut.Targets[1].Formats = []string{"png", "avif"}
+	if formats := fanOut.Formats(); !reflect.DeepEqual(formats, []string{"png", "avif", "jpeg", "jpg"}) {
+		t.Errorf("Unexpected formats %v", formats)
+	}
+	fanOut.Targets[1].Formats = []string{"avif"}
+	if formats := fanOut.Formats(); !reflect.DeepEqual(formats, []string{"avif"}) {
+		t.Errorf("Pictures picked in formats that can't be converted to avif, %v", formats)
+	}
+}
+
+func TestFanOutApplied(t *testing.T) {
+	applied, failed := Result{}, Result{Err: errors.New("broken")}
+	tests := []struct {
+		optional []bool
+		results  []Result
+		expected bool
+	}{
+		// Targets that aren't optional all have to apply the wallpaper
+		{[]bool{false, false}, []Result{applied, failed}, false},
+		{[]bool{false, true}, []Result{applied, failed}, true},
+		{[]bool{false, true}, []Result{failed, applied}, false},
+		// With only optional ones any of them is enough
+		{[]bool{true, true}, []Result{failed, applied}, true},
+		{[]bool{true, true}, []Result{failed, failed}, false},
+	}
+	for _, test := range tests {
+		fanOut := NewFanOut()
+		for _, optional := range test.optional {
+			fanOut.Targets = append(fanOut.Targets, &Target{BackgroundChanger: &fakeBackgroundChanger{}, Optional: optional})
+		}
+		if result := fanOut.Applied(test.results); result != test.expected {
+			t.Errorf("Optional %v with %v applied is %v, expected %v", test.optional, test.results, result, test.expected)
+		}
+	}
+}
+
+func TestTargetStats(t *testing.T) {
+	fbc := &fakeBackgroundChanger{errs: []error{nil, errors.New("broken")}}
+	target := &Target{Name: "desktop", BackgroundChanger: fbc}
+	if stats := target.Stats(); stats.AverageLatency() != 0 {
+		t.Errorf("Unexpected latency before applying anything, %v", stats)
+	}
+	var total time.Duration
+	var last Result
+	for attempt := 0; attempt < 3; attempt++ {
+		last = target.apply(context.Background(), Wallpaper{Picture: "/pictures/a.jpg"}, 0, 0)
+		total += last.Latency
+	}
+	stats := target.Stats()
+	if stats.Applied != 2 || stats.Failed != 1 {
+		t.Errorf("Expected 2 applied and 1 failed, got %v", stats)
+	}
+	if stats.LastLatency != last.Latency || stats.AverageLatency() != total/3 {
+		t.Errorf("Expected latency %v last and %v average, got %v", last.Latency, total/3, stats)
+	}
+}
@@ -1,34 +1,200 @@
 package de
 
 import (
-	"os/exec"
-
+	"os"
 	"runtime"
+	"strings"
+
+	"github.com/txomon/sawyer/pkg/util"
 )
 
-type GnomeShellBackgroundChanger struct{}
+const gnomeBackgroundSchema = "org.gnome.desktop.background"
+
+const (
+	gnomeDarkSame       = "same"
+	gnomeDarkBrightness = "brightness"
+)
 
-func (lbc *GnomeShellBackgroundChanger) Set(pictureStream chan string) {
-	for {
-		picture := <-pictureStream
-		command := exec.Command("gsettings", "set", "org.gnome.desktop.background", "picture-uri", picture)
-		err := command.Run()
+var gnomePictureOptions = []string{"none", "wallpaper", "centered", "scaled", "stretched", "zoom", "spanned"}
+
+var gnomeShadingTypes = []string{"solid", "horizontal", "vertical"}
+
+// GnomeShellBackgroundChanger sets the picture for both light and dark
+// styles. In brightness dark mode each picture only goes to the style it
+// suits, so dark style rotates through dark pictures and light style
+// through light ones.
+//
+// GNOME can only show one picture, so different pictures per output are
+// composed into one spanning all of them.
+type GnomeShellBackgroundChanger struct {
+	lightPicture        string
+	darkPicture         string
+	spanned             bool
+	pictureOptions      string
+	primaryColor        string
+	secondaryColor      string
+	colorShadingType    string
+	darkMode            string
+	brightnessThreshold float64
+	darkUnsupported     bool
+}
+
+func (lbc *GnomeShellBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	picture := wallpaper.Picture
+	if len(wallpaper.Outputs) > 0 {
+		outputs, err := lbc.GetOutputs()
 		if err != nil {
-			logger.Errorf("%v", err)
+			return err
+		}
+		if picture, err = composeOutputs(outputs, wallpaper); err != nil {
+			return err
 		}
 	}
+
+	if err := lbc.setAppearance(len(wallpaper.Outputs) > 0); err != nil {
+		return err
+	}
+
+	light, dark := true, !lbc.darkUnsupported
+	if dark && lbc.darkMode == gnomeDarkBrightness {
+		if brightness, err := util.Brightness(picture); err != nil {
+			logger.Warningf("Failed to measure brightness of %v, using it for both styles. %v", picture, err)
+		} else {
+			dark = brightness < lbc.brightnessThreshold
+			light = !dark
+			logger.Debugf("Picture %v has brightness %.2f", picture, brightness)
+		}
+	}
+	if light {
+		if err := gsettingsSet(gnomeBackgroundSchema, "picture-uri", fileURI(picture)); err != nil {
+			return err
+		}
+	}
+	if dark {
+		if err := gsettingsSet(gnomeBackgroundSchema, "picture-uri-dark", fileURI(picture)); err != nil {
+			// Only GNOME 42 onwards has a picture for dark style, other
+			// failures are retried like any other
+			if hasKey, keyErr := gsettingsHasKey(gnomeBackgroundSchema, "picture-uri-dark"); keyErr != nil || hasKey {
+				return err
+			}
+			logger.Infof("Not setting dark style pictures anymore, there is no picture-uri-dark. %v", err)
+			lbc.darkUnsupported = true
+			if !light {
+				return gsettingsSet(gnomeBackgroundSchema, "picture-uri", fileURI(picture))
+			}
+		}
+	}
+
+	// Composed pictures are removed once neither style shows them
+	previous := []string{lbc.lightPicture, lbc.darkPicture}
+	if light {
+		lbc.lightPicture = picture
+	}
+	if dark && !lbc.darkUnsupported {
+		lbc.darkPicture = picture
+	}
+	for _, old := range previous {
+		if old != "" && inWorkDirectory(old) && old != lbc.lightPicture && old != lbc.darkPicture {
+			os.Remove(old)
+		}
+	}
+	return nil
 }
+
+// setAppearance sets how the picture is laid out, only touching what is
+// configured. Composed pictures have to be spanned, and once they stop
+// being used zoom is restored unless other option is configured.
+func (lbc *GnomeShellBackgroundChanger) setAppearance(composed bool) error {
+	options := lbc.pictureOptions
+	if composed {
+		options = "spanned"
+	} else if options == "" && lbc.spanned {
+		options = "zoom"
+	}
+	lbc.spanned = composed
+	settings := [][2]string{
+		{"picture-options", options},
+		{"primary-color", lbc.primaryColor},
+		{"secondary-color", lbc.secondaryColor},
+		{"color-shading-type", lbc.colorShadingType},
+	}
+	for _, setting := range settings {
+		if setting[1] == "" {
+			continue
+		}
+		if err := gsettingsSet(gnomeBackgroundSchema, setting[0], setting[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOutputs relies on xrandr, which under Wayland reports the outputs
+// Xwayland mirrors from mutter.
+func (lbc *GnomeShellBackgroundChanger) GetOutputs() ([]Output, error) {
+	return xrandrOutputs()
+}
+
 func (lbc *GnomeShellBackgroundChanger) GetSupportedFormats() []string {
 	return []string{"jpeg", "png", "jpg"}
 }
 
-func GnomeShellDetect() DEBackgroundChanger {
+func GnomeShellDetect(session *Session) Detection {
 	if runtime.GOOS != "linux" {
+		return Rejected("only available on linux")
+	}
+	var detection Detection
+	detection.Add(session.DesktopScore("gnome", "unity"), "XDG_CURRENT_DESKTOP is %v", session.Getenv("XDG_CURRENT_DESKTOP"))
+	detection.Add(session.SessionScore("gnome", "ubuntu"), "DESKTOP_SESSION is %v", session.Getenv("DESKTOP_SESSION"))
+	detection.Add(session.ProcessScore("gnome-shell"), "gnome-shell is running")
+	detection.Add(session.EnvironmentScore("GNOME_DESKTOP_SESSION_ID"), "GNOME_DESKTOP_SESSION_ID is set")
+	return detection
+}
+
+func isOneOf(value string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func GetGnomeShellBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	lbc := &GnomeShellBackgroundChanger{darkMode: gnomeDarkSame, brightnessThreshold: 0.5}
+	lbc.pictureOptions, _ = config["picture_options"].(string)
+	lbc.primaryColor, _ = config["primary_color"].(string)
+	lbc.secondaryColor, _ = config["secondary_color"].(string)
+	lbc.colorShadingType, _ = config["color_shading_type"].(string)
+	if darkMode, ok := config["dark_mode"].(string); ok {
+		lbc.darkMode = darkMode
+	}
+	if threshold, ok := config["brightness_threshold"].(float64); ok {
+		lbc.brightnessThreshold = threshold
+	}
+
+	if lbc.pictureOptions != "" && !isOneOf(lbc.pictureOptions, gnomePictureOptions) {
+		logger.Errorf("picture_options %v is not one of %v", lbc.pictureOptions, strings.Join(gnomePictureOptions, ", "))
 		return nil
 	}
-	return &GnomeShellBackgroundChanger{}
+	if lbc.colorShadingType != "" && !isOneOf(lbc.colorShadingType, gnomeShadingTypes) {
+		logger.Errorf("color_shading_type %v is not one of %v", lbc.colorShadingType, strings.Join(gnomeShadingTypes, ", "))
+		return nil
+	}
+	if !isOneOf(lbc.darkMode, []string{gnomeDarkSame, gnomeDarkBrightness}) {
+		logger.Errorf("dark_mode %v is not one of %v, %v", lbc.darkMode, gnomeDarkSame, gnomeDarkBrightness)
+		return nil
+	}
+	return lbc
+}
+
+// GetGnomeScreensaverBackgroundChanger sets the picture of the GNOME lock
+// screen, which has its own key next to the desktop one.
+func GetGnomeScreensaverBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &GSettingsBackgroundChanger{schema: "org.gnome.desktop.screensaver", key: "picture-uri", uri: true}
 }
 
 func init() {
-	RegisterDE("gnome-shell", GnomeShellDetect)
+	RegisterDE("gnome-shell", PriorityDesktop, GnomeShellDetect, GetGnomeShellBackgroundChanger)
+	RegisterLockScreen("gnome-shell", GetGnomeScreensaverBackgroundChanger)
 }
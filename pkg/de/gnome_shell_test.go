@@ -0,0 +1,130 @@
+package de
+
+import (
+	"errors"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gsettingsCalls stubs runCommand recording the gsettings calls made. The
+// keys in failing are missing from the schema, so setting them fails.
+func gsettingsCalls(t *testing.T, failing ...string) *[]string {
+	var calls []string
+	stubRunner(t, func(command []string) ([]byte, error) {
+		calls = append(calls, strings.Join(command, " "))
+		if command[1] == "list-keys" {
+			keys := "picture-options\npicture-uri\npicture-uri-dark\nprimary-color\n"
+			for _, key := range failing {
+				keys = strings.Replace(keys, key+"\n", "", 1)
+			}
+			return []byte(keys), nil
+		}
+		for _, key := range failing {
+			if command[1] == "set" && command[3] == key {
+				return []byte("No such key"), errors.New("exit status 1")
+			}
+		}
+		return nil, nil
+	})
+	return &calls
+}
+
+func TestGnomeShellApply(t *testing.T) {
+	calls := gsettingsCalls(t)
+	config := map[string]interface{}{"picture_options": "scaled", "primary_color": "#000000"}
+	lbc := GetGnomeShellBackgroundChanger(config).(*GnomeShellBackgroundChanger)
+	if err := lbc.Apply(Wallpaper{Picture: "/pictures/a b.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"gsettings set org.gnome.desktop.background picture-options scaled",
+		"gsettings set org.gnome.desktop.background primary-color #000000",
+		"gsettings set org.gnome.desktop.background picture-uri file:///pictures/a%20b.jpg",
+		"gsettings set org.gnome.desktop.background picture-uri-dark file:///pictures/a%20b.jpg",
+	}
+	if !reflect.DeepEqual(*calls, expected) {
+		t.Errorf("Expected calls %q, got %q", expected, *calls)
+	}
+}
+
+func TestGnomeShellWithoutDarkKey(t *testing.T) {
+	calls := gsettingsCalls(t, "picture-uri-dark")
+	lbc := GetGnomeShellBackgroundChanger(nil).(*GnomeShellBackgroundChanger)
+	if err := lbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	if !lbc.darkUnsupported {
+		t.Errorf("Dark style still enabled without picture-uri-dark")
+	}
+	*calls = nil
+	if err := lbc.Apply(Wallpaper{Picture: "/pictures/b.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"gsettings set org.gnome.desktop.background picture-uri file:///pictures/b.jpg"}
+	if !reflect.DeepEqual(*calls, expected) {
+		t.Errorf("Expected calls %q, got %q", expected, *calls)
+	}
+}
+
+func TestGnomeShellDarkFailureIsRetried(t *testing.T) {
+	failing := true
+	stubRunner(t, func(command []string) ([]byte, error) {
+		if command[1] == "list-keys" {
+			return []byte("picture-uri\npicture-uri-dark\n"), nil
+		}
+		if failing && command[1] == "set" && command[3] == "picture-uri-dark" {
+			return []byte("failed"), errors.New("exit status 1")
+		}
+		return nil, nil
+	})
+	lbc := GetGnomeShellBackgroundChanger(nil).(*GnomeShellBackgroundChanger)
+	if err := lbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err == nil || !IsRetryable(err) {
+		t.Errorf("Expected a retryable error, got %v", err)
+	}
+	if lbc.darkUnsupported {
+		t.Errorf("Dark style disabled when the key exists")
+	}
+	// Once the failure goes away dark style is set again
+	failing = false
+	if err := lbc.Apply(Wallpaper{Picture: "/pictures/b.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGnomeShellBrightness(t *testing.T) {
+	dark := solidPicture(t, color.RGBA{R: 20, G: 20, B: 20, A: 255})
+	light := solidPicture(t, color.RGBA{R: 230, G: 230, B: 230, A: 255})
+	calls := gsettingsCalls(t)
+	lbc := GetGnomeShellBackgroundChanger(map[string]interface{}{"dark_mode": "brightness"}).(*GnomeShellBackgroundChanger)
+	for picture, key := range map[string]string{dark: "picture-uri-dark", light: "picture-uri"} {
+		*calls = nil
+		if err := lbc.Apply(Wallpaper{Picture: picture}); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"gsettings set org.gnome.desktop.background " + key + " " + fileURI(picture)}
+		if !reflect.DeepEqual(*calls, expected) {
+			t.Errorf("Expected calls %q, got %q", expected, *calls)
+		}
+	}
+	if lbc.lightPicture != light || lbc.darkPicture != dark {
+		t.Errorf("Expected light %v and dark %v, got %v and %v", light, dark, lbc.lightPicture, lbc.darkPicture)
+	}
+}
+
+func TestGnomeShellConfig(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"picture_options": "fit"},
+		{"color_shading_type": "radial"},
+		{"dark_mode": "night"},
+	} {
+		if lbc := GetGnomeShellBackgroundChanger(config); lbc != nil {
+			t.Errorf("Accepted invalid config %v", config)
+		}
+	}
+	lbc := GetGnomeShellBackgroundChanger(map[string]interface{}{"brightness_threshold": 0.3}).(*GnomeShellBackgroundChanger)
+	if lbc.darkMode != gnomeDarkSame || lbc.brightnessThreshold != 0.3 {
+		t.Errorf("Unexpected defaults %v %v", lbc.darkMode, lbc.brightnessThreshold)
+	}
+}
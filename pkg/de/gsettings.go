@@ -0,0 +1,92 @@
+package de
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// GSettingsBackgroundChanger sets a single gsettings key, which is all most
+// GNOME derived desktops need. Some of them store a file:// URI and others
+// a plain path.
+type GSettingsBackgroundChanger struct {
+	schema string
+	key    string
+	uri    bool
+}
+
+func (gbc *GSettingsBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	picture := wallpaper.Picture
+	value := picture
+	if gbc.uri {
+		value = fileURI(picture)
+	}
+	return gsettingsSet(gbc.schema, gbc.key, value)
+}
+
+func gsettingsSet(schema, key, value string) error {
+	output, err := runCommand("gsettings", "set", schema, key, value)
+	if err != nil {
+		return Retryable(fmt.Errorf("gsettings set %v %v failed: %v %s", schema, key, err, output))
+	}
+	return nil
+}
+
+// gsettingsHasKey tells whether the installed schema has the key, as keys
+// come and go between desktop versions
+func gsettingsHasKey(schema, key string) (bool, error) {
+	output, err := runCommand("gsettings", "list-keys", schema)
+	if err != nil {
+		return false, Retryable(fmt.Errorf("gsettings list-keys %v failed: %v %s", schema, err, output))
+	}
+	for _, listed := range strings.Fields(string(output)) {
+		if listed == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (gbc *GSettingsBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+// gsettingsDetect builds the detection of a desktop from its names in
+// XDG_CURRENT_DESKTOP and DESKTOP_SESSION and the process drawing it.
+func gsettingsDetect(desktops []string, sessions []string, process string) func(*Session) Detection {
+	return func(session *Session) Detection {
+		if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+			return Rejected("only available on linux and freebsd")
+		}
+		var detection Detection
+		detection.Add(session.DesktopScore(desktops...), "XDG_CURRENT_DESKTOP is %v", session.Getenv("XDG_CURRENT_DESKTOP"))
+		detection.Add(session.SessionScore(sessions...), "DESKTOP_SESSION is %v", session.Getenv("DESKTOP_SESSION"))
+		detection.Add(session.ProcessScore(process), "%v is running", process)
+		return detection
+	}
+}
+
+var (
+	CinnamonDetect = gsettingsDetect([]string{"x-cinnamon", "cinnamon"}, []string{"cinnamon"}, "cinnamon")
+	MateDetect     = gsettingsDetect([]string{"mate"}, []string{"mate"}, "mate-session")
+	BudgieDetect   = gsettingsDetect([]string{"budgie"}, []string{"budgie"}, "budgie-panel")
+)
+
+func GetCinnamonBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &GSettingsBackgroundChanger{schema: "org.cinnamon.desktop.background", key: "picture-uri", uri: true}
+}
+
+func GetMateBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &GSettingsBackgroundChanger{schema: "org.mate.background", key: "picture-filename"}
+}
+
+func GetBudgieBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &GSettingsBackgroundChanger{schema: "org.gnome.desktop.background", key: "picture-uri", uri: true}
+}
+
+func init() {
+	RegisterDE("cinnamon", PriorityDesktop, CinnamonDetect, GetCinnamonBackgroundChanger)
+	RegisterDE("mate", PriorityDesktop, MateDetect, GetMateBackgroundChanger)
+	RegisterDE("budgie", PriorityDesktop, BudgieDetect, GetBudgieBackgroundChanger)
+	RegisterLockScreen("budgie", GetGnomeScreensaverBackgroundChanger)
+}
@@ -0,0 +1,43 @@
+package de
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestGSettingsDetectAndApply(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("gsettings desktops are only detected on linux and freebsd")
+	}
+	tests := []struct {
+		desktop     string
+		detect      func(*Session) Detection
+		constructor func(map[string]interface{}) DEBackgroundChanger
+		command     []string
+	}{
+		{"X-Cinnamon", CinnamonDetect, GetCinnamonBackgroundChanger, []string{"gsettings", "set", "org.cinnamon.desktop.background", "picture-uri", "file:///pictures/a%20b.jpg"}},
+		{"MATE", MateDetect, GetMateBackgroundChanger, []string{"gsettings", "set", "org.mate.background", "picture-filename", "/pictures/a b.jpg"}},
+		{"Budgie:GNOME", BudgieDetect, GetBudgieBackgroundChanger, []string{"gsettings", "set", "org.gnome.desktop.background", "picture-uri", "file:///pictures/a%20b.jpg"}},
+	}
+	for _, test := range tests {
+		stubSession(t, map[string]string{"XDG_CURRENT_DESKTOP": test.desktop})
+		if detection := test.detect(NewSession(nil)); !detection.Accepted() {
+			t.Errorf("Not detected in %v, %v", test.desktop, detection)
+		}
+		commands := stubCommands(t, nil)
+		if err := test.constructor(nil).(*GSettingsBackgroundChanger).Apply(Wallpaper{Picture: "/pictures/a b.jpg"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(*commands) != 1 || !reflect.DeepEqual((*commands)[0], test.command) {
+			t.Errorf("Expected %q in %v, got %q", test.command, test.desktop, *commands)
+		}
+	}
+
+	stubSession(t, map[string]string{"XDG_CURRENT_DESKTOP": "GNOME"})
+	for _, detect := range []func(*Session) Detection{CinnamonDetect, MateDetect, BudgieDetect} {
+		if detection := detect(NewSession(nil)); detection.Accepted() {
+			t.Errorf("Detected in GNOME, %v", detection)
+		}
+	}
+}
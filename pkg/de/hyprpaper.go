@@ -0,0 +1,97 @@
+package de
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+type HyprpaperBackgroundChanger struct {
+	mutex   sync.Mutex
+	current string
+}
+
+func (hbc *HyprpaperBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	picture := wallpaper.Picture
+	hbc.mutex.Lock()
+	defer hbc.mutex.Unlock()
+
+	if err := hyprpaperRequest("preload", picture); err != nil {
+		return err
+	}
+	if err := hyprpaperRequest("wallpaper", ","+picture); err != nil {
+		return err
+	}
+	// hyprpaper keeps every preloaded picture in memory until told otherwise
+	if hbc.current != "" && hbc.current != picture {
+		if err := hyprpaperRequest("unload", hbc.current); err != nil {
+			logger.Warningf("Failed to unload %v from hyprpaper. %v", hbc.current, err)
+		}
+	}
+	hbc.current = picture
+	return nil
+}
+
+func (hbc *HyprpaperBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+// hyprpaperRequest sends an IPC request through hyprctl, which exits with 0
+// even when hyprpaper refuses it, so the answer has to be checked too.
+func hyprpaperRequest(args ...string) error {
+	output, err := runCommand("hyprctl", append([]string{"hyprpaper"}, args...)...)
+	if err != nil {
+		return Retryable(fmt.Errorf("hyprctl hyprpaper %v failed: %v %s", args[0], err, output))
+	}
+	if answer := strings.TrimSpace(string(output)); answer != "ok" {
+		return Retryable(fmt.Errorf("hyprctl hyprpaper %v failed: %v", args[0], answer))
+	}
+	return nil
+}
+
+// hyprlandSocketDir is where Hyprland and hyprpaper keep their sockets,
+// older releases used /tmp instead of XDG_RUNTIME_DIR.
+func hyprlandSocketDir(session *Session) string {
+	signature := session.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if signature == "" {
+		return ""
+	}
+	if runtimeDir := session.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		if directory := filepath.Join(runtimeDir, "hypr", signature); session.HasSocket(filepath.Join(directory, ".socket.sock")) {
+			return directory
+		}
+	}
+	return filepath.Join("/tmp", "hypr", signature)
+}
+
+func isHyprpaperRunning(session *Session) bool {
+	if session.IsRunning("hyprpaper") {
+		return true
+	}
+	directory := hyprlandSocketDir(session)
+	return directory != "" && session.HasSocket(filepath.Join(directory, ".hyprpaper.sock"))
+}
+
+func HyprpaperDetect(session *Session) Detection {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return Rejected("only available on linux and freebsd")
+	}
+	if !isHyprpaperRunning(session) {
+		return Rejected("hyprpaper is not running")
+	}
+	var detection Detection
+	detection.Add(session.DesktopScore("hyprland"), "XDG_CURRENT_DESKTOP is %v", session.Getenv("XDG_CURRENT_DESKTOP"))
+	detection.Add(session.EnvironmentScore("HYPRLAND_INSTANCE_SIGNATURE"), "HYPRLAND_INSTANCE_SIGNATURE is set")
+	detection.Add(session.ProcessScore("Hyprland"), "Hyprland is running")
+	return detection
+}
+
+func GetHyprpaperBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &HyprpaperBackgroundChanger{}
+}
+
+func init() {
+	RegisterDE("hyprpaper", PrioritySpecific, HyprpaperDetect, GetHyprpaperBackgroundChanger)
+}
@@ -0,0 +1,60 @@
+package de
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// stubHyprctl answers every hyprctl request with answer, recording them
+func stubHyprctl(t *testing.T, answer string) *[][]string {
+	return stubRunner(t, func(command []string) ([]byte, error) {
+		return []byte(answer + "\n"), nil
+	})
+}
+
+func TestHyprpaperApply(t *testing.T) {
+	requests := stubHyprctl(t, "ok")
+	hbc := &HyprpaperBackgroundChanger{}
+	for _, picture := range []string{"/pictures/a.jpg", "/pictures/b.jpg"} {
+		if err := hbc.Apply(Wallpaper{Picture: picture}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := [][]string{
+		{"hyprctl", "hyprpaper", "preload", "/pictures/a.jpg"},
+		{"hyprctl", "hyprpaper", "wallpaper", ",/pictures/a.jpg"},
+		{"hyprctl", "hyprpaper", "preload", "/pictures/b.jpg"},
+		{"hyprctl", "hyprpaper", "wallpaper", ",/pictures/b.jpg"},
+		{"hyprctl", "hyprpaper", "unload", "/pictures/a.jpg"},
+	}
+	if !reflect.DeepEqual(*requests, expected) {
+		t.Errorf("Expected %q, got %q", expected, *requests)
+	}
+
+	stubHyprctl(t, "wallpaper failed (not preloaded)")
+	if err := hbc.Apply(Wallpaper{Picture: "/pictures/c.jpg"}); err == nil {
+		t.Errorf("Refused wallpaper reported as set")
+	}
+}
+
+func TestHyprpaperDetect(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("hyprpaper is only detected on linux and freebsd")
+	}
+	environment := map[string]string{"HYPRLAND_INSTANCE_SIGNATURE": "abc", "XDG_RUNTIME_DIR": "/run/user/1000"}
+	stubSession(t, environment, "Hyprland", "hyprpaper")
+	if detection := HyprpaperDetect(NewSession(nil)); detection.Score != ScoreEnvironment+ScoreProcess {
+		t.Errorf("hyprpaper not detected in Hyprland, %v", detection)
+	}
+	// hyprpaper is found by its socket when running under another name
+	stubSession(t, environment)
+	stubSockets(t, "/run/user/1000/hypr/abc/.socket.sock", "/run/user/1000/hypr/abc/.hyprpaper.sock")
+	if detection := HyprpaperDetect(NewSession(nil)); !detection.Accepted() {
+		t.Errorf("hyprpaper not detected by its socket, %v", detection)
+	}
+	stubSockets(t)
+	if detection := HyprpaperDetect(NewSession(nil)); detection.Accepted() {
+		t.Errorf("hyprpaper detected when not running, %v", detection)
+	}
+}
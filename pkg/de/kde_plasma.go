@@ -0,0 +1,90 @@
+package de
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+)
+
+// Script run by plasmashell, it walks every desktop containment (one per
+// screen and activity) and points its image wallpaper to the picture.
+const kdePlasmaScript = `var allDesktops = desktops();
+for (var i = 0; i < allDesktops.length; i++) {
+	var desktop = allDesktops[i];
+	desktop.wallpaperPlugin = "org.kde.image";
+	desktop.currentConfigGroup = Array("Wallpaper", "org.kde.image", "General");
+	desktop.writeConfig("Image", %v);
+}`
+
+type KdePlasmaBackgroundChanger struct{}
+
+func (kbc *KdePlasmaBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	picture := wallpaper.Picture
+	uri, err := json.Marshal(fileURI(picture))
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(kdePlasmaScript, string(uri))
+	output, err := runCommand("dbus-send", "--session", "--print-reply", "--type=method_call",
+		"--dest=org.kde.plasmashell", "/PlasmaShell", "org.kde.PlasmaShell.evaluateScript",
+		"string:"+script)
+	if err != nil {
+		return Retryable(fmt.Errorf("plasmashell script failed: %v %s", err, output))
+	}
+	return nil
+}
+
+func (kbc *KdePlasmaBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+func KdePlasmaDetect(session *Session) Detection {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return Rejected("only available on linux and freebsd")
+	}
+	var detection Detection
+	detection.Add(session.DesktopScore("kde"), "XDG_CURRENT_DESKTOP is %v", session.Getenv("XDG_CURRENT_DESKTOP"))
+	detection.Add(session.SessionScore("plasma", "kde"), "DESKTOP_SESSION is %v", session.Getenv("DESKTOP_SESSION"))
+	detection.Add(session.ProcessScore("plasmashell"), "plasmashell is running")
+	detection.Add(session.EnvironmentScore("KDE_FULL_SESSION"), "KDE_FULL_SESSION is set")
+	return detection
+}
+
+func GetKdePlasmaBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &KdePlasmaBackgroundChanger{}
+}
+
+// KdeLockScreenBackgroundChanger writes the picture in the configuration of
+// the image plugin of kscreenlocker, read every time the screen is locked.
+type KdeLockScreenBackgroundChanger struct {
+	kwriteconfig string
+}
+
+func (kbc *KdeLockScreenBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	uri := fileURI(wallpaper.Picture)
+	output, err := runCommand(kbc.kwriteconfig, "--file", "kscreenlockerrc",
+		"--group", "Greeter", "--group", "Wallpaper", "--group", "org.kde.image", "--group", "General",
+		"--key", "Image", uri)
+	if err != nil {
+		return Retryable(fmt.Errorf("%v failed: %v %s", kbc.kwriteconfig, err, output))
+	}
+	return nil
+}
+
+func (kbc *KdeLockScreenBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+func GetKdeLockScreenBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	for _, kwriteconfig := range []string{"kwriteconfig6", "kwriteconfig5"} {
+		if _, err := lookPath(kwriteconfig); err == nil {
+			return &KdeLockScreenBackgroundChanger{kwriteconfig: kwriteconfig}
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterDE("kde-plasma", PriorityDesktop, KdePlasmaDetect, GetKdePlasmaBackgroundChanger)
+	RegisterLockScreen("kde-plasma", GetKdeLockScreenBackgroundChanger)
+}
@@ -0,0 +1,70 @@
+package de
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestKdePlasmaDetect(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("KDE Plasma is only detected on linux and freebsd")
+	}
+	stubSession(t, map[string]string{"XDG_CURRENT_DESKTOP": "KDE", "KDE_FULL_SESSION": "true"}, "plasmashell")
+	detection := KdePlasmaDetect(NewSession(nil))
+	if !detection.Accepted() || detection.Score != ScoreCurrentDesktop+ScoreProcess+ScoreEnvironment {
+		t.Errorf("Expected KDE Plasma to be detected, got %v %v", detection.Score, detection)
+	}
+
+	stubSession(t, map[string]string{"XDG_CURRENT_DESKTOP": "GNOME", "DESKTOP_SESSION": "gnome"}, "gnome-shell")
+	if detection := KdePlasmaDetect(NewSession(nil)); detection.Accepted() {
+		t.Errorf("KDE Plasma detected in GNOME, %v", detection)
+	}
+}
+
+func TestKdePlasmaApply(t *testing.T) {
+	commands := stubCommands(t, nil)
+	kbc := &KdePlasmaBackgroundChanger{}
+	if err := kbc.Apply(Wallpaper{Picture: `/pictures/a "quoted".jpg`}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*commands) != 1 {
+		t.Fatalf("Expected one command, got %v", *commands)
+	}
+	command := (*commands)[0]
+	if command[0] != "dbus-send" || !strings.Contains(strings.Join(command, " "), "--dest=org.kde.plasmashell") {
+		t.Errorf("Unexpected command %v", command)
+	}
+	script := command[len(command)-1]
+	if !strings.HasPrefix(script, "string:") || !strings.Contains(script, `desktop.writeConfig("Image", "file:///pictures/a%20%22quoted%22.jpg")`) {
+		t.Errorf("Picture not set by the script %v", script)
+	}
+
+	stubCommands(t, errors.New("exit status 1"))
+	if err := kbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"}); !IsRetryable(err) || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("Expected a retryable error with the command output, got %v", err)
+	}
+}
+
+func TestKdeLockScreen(t *testing.T) {
+	stubInstalled(t, "kwriteconfig5")
+	commands := stubCommands(t, nil)
+
+	changer := GetKdeLockScreenBackgroundChanger(nil)
+	if changer == nil {
+		t.Fatal("Lock screen not available with kwriteconfig5")
+	}
+	if err := changer.(*KdeLockScreenBackgroundChanger).Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	command := strings.Join((*commands)[0], " ")
+	if !strings.HasPrefix(command, "kwriteconfig5 --file kscreenlockerrc") || !strings.HasSuffix(command, "--key Image file:///pictures/a.jpg") {
+		t.Errorf("Unexpected command %v", command)
+	}
+
+	stubInstalled(t)
+	if changer := GetKdeLockScreenBackgroundChanger(nil); changer != nil {
+		t.Errorf("Lock screen available without kwriteconfig")
+	}
+}
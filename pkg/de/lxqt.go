@@ -0,0 +1,40 @@
+package de
+
+import (
+	"fmt"
+	"runtime"
+)
+
+type LxqtBackgroundChanger struct{}
+
+func (lbc *LxqtBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	picture := wallpaper.Picture
+	output, err := runCommand("pcmanfm-qt", "--set-wallpaper", picture, "--wallpaper-mode", "zoom")
+	if err != nil {
+		return Retryable(fmt.Errorf("pcmanfm-qt failed: %v %s", err, output))
+	}
+	return nil
+}
+
+func (lbc *LxqtBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+func LxqtDetect(session *Session) Detection {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return Rejected("only available on linux and freebsd")
+	}
+	var detection Detection
+	detection.Add(session.DesktopScore("lxqt"), "XDG_CURRENT_DESKTOP is %v", session.Getenv("XDG_CURRENT_DESKTOP"))
+	detection.Add(session.SessionScore("lxqt"), "DESKTOP_SESSION is %v", session.Getenv("DESKTOP_SESSION"))
+	detection.Add(session.ProcessScore("lxqt-session"), "lxqt-session is running")
+	return detection
+}
+
+func GetLxqtBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &LxqtBackgroundChanger{}
+}
+
+func init() {
+	RegisterDE("lxqt", PriorityDesktop, LxqtDetect, GetLxqtBackgroundChanger)
+}
@@ -0,0 +1,30 @@
+package de
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestLxqt(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("LXQt is only detected on linux and freebsd")
+	}
+	stubSession(t, map[string]string{"XDG_CURRENT_DESKTOP": "LXQt"}, "lxqt-session")
+	if detection := LxqtDetect(NewSession(nil)); !detection.Accepted() {
+		t.Errorf("LXQt not detected, %v", detection)
+	}
+	commands := stubCommands(t, nil)
+	if err := GetLxqtBackgroundChanger(nil).(*LxqtBackgroundChanger).Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"pcmanfm-qt", "--set-wallpaper", "/pictures/a.jpg", "--wallpaper-mode", "zoom"}
+	if len(*commands) != 1 || !reflect.DeepEqual((*commands)[0], expected) {
+		t.Errorf("Expected %q, got %q", expected, *commands)
+	}
+
+	stubSession(t, map[string]string{"XDG_CURRENT_DESKTOP": "LXDE"}, "lxsession")
+	if detection := LxqtDetect(NewSession(nil)); detection.Accepted() {
+		t.Errorf("LXQt detected in LXDE, %v", detection)
+	}
+}
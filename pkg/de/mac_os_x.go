@@ -30,26 +30,30 @@ import (
 
 type MacOsXBackgroundChanger struct{}
 
-func (lbc *MacOsXBackgroundChanger) ChangeBackground(pictureStream chan string) {
-	for {
-		picture := <-pictureStream
-		logger.Infof("Picture would be %v", picture)
-		pictureString := C.CString(picture)
-		defer C.free((unsafe.Pointer)(pictureString))
-		C.change_background(pictureString)
-	}
+func (lbc *MacOsXBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	picture := wallpaper.Picture
+	pictureString := C.CString(picture)
+	defer C.free((unsafe.Pointer)(pictureString))
+	C.change_background(pictureString)
+	return nil
 }
 func (lbc *MacOsXBackgroundChanger) GetSupportedFormats() []string {
 	return []string{"jpeg", "png", "jpg"}
 }
 
-func MacOsXDetect() DEBackgroundChanger {
+func MacOsXDetect(session *Session) Detection {
 	if runtime.GOOS != "darwin" {
-		return nil
+		return Rejected("only available on darwin")
 	}
+	var detection Detection
+	detection.Add(ScoreCurrentDesktop, "running on darwin")
+	return detection
+}
+
+func GetMacOsXBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
 	return &MacOsXBackgroundChanger{}
 }
 
 func init() {
-	RegisterDE("mac-os-x", MacOsXDetect)
+	RegisterDE("mac-os-x", PrioritySpecific, MacOsXDetect, GetMacOsXBackgroundChanger)
 }
@@ -0,0 +1,161 @@
+package de
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+// RecordBackgroundChanger doesn't touch any desktop, it appends every
+// wallpaper applied to a JSON-lines file and optionally points a symlink
+// at the current picture. It's meant for headless runs, tests and feeding
+// wallpapers to other tools.
+type RecordBackgroundChanger struct {
+	mutex   sync.Mutex
+	file    string
+	current string
+	formats []string
+	outputs []Output
+}
+
+// RecordEntry is a line of the record file, one per output changed
+type RecordEntry struct {
+	Time     time.Time              `json:"time"`
+	Output   string                 `json:"output,omitempty"`
+	Path     string                 `json:"path"`
+	Source   string                 `json:"source,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+func (rbc *RecordBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	rbc.mutex.Lock()
+	defer rbc.mutex.Unlock()
+
+	now := time.Now()
+	var entries []RecordEntry
+	if len(rbc.outputs) == 0 {
+		entries = append(entries, recordEntry(now, "", wallpaper.Picture))
+	}
+	for _, output := range rbc.outputs {
+		entries = append(entries, recordEntry(now, output.Name, wallpaper.GetPicture(output.Name)))
+	}
+	if err := rbc.record(entries); err != nil {
+		return err
+	}
+	if rbc.current != "" {
+		return symlinkAtomic(wallpaper.Picture, rbc.current)
+	}
+	return nil
+}
+
+func recordEntry(now time.Time, output, picture string) RecordEntry {
+	metadata, err := util.ReadMetadata(picture)
+	if err != nil {
+		logger.Infof("Failed to read metadata of %v. %v", picture, err)
+	}
+	// Providers name themselves in the metadata, pictures without it are
+	// told apart by the directory they are cached in
+	source, _ := metadata["source"].(string)
+	if source == "" {
+		source = filepath.Base(filepath.Dir(picture))
+	}
+	return RecordEntry{
+		Time:     now,
+		Output:   output,
+		Path:     picture,
+		Source:   source,
+		Metadata: metadata,
+	}
+}
+
+func (rbc *RecordBackgroundChanger) record(entries []RecordEntry) error {
+	recordFile := rbc.file
+	if recordFile == "" {
+		recordFile = filepath.Join(workDirectory, "record.jsonl")
+	}
+	if err := os.MkdirAll(filepath.Dir(recordFile), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(recordFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to record %v: %v", entry.Path, err)
+		}
+	}
+	return file.Close()
+}
+
+func (rbc *RecordBackgroundChanger) GetOutputs() ([]Output, error) {
+	if len(rbc.outputs) == 0 {
+		return nil, ErrOutputsUnsupported
+	}
+	return rbc.outputs, nil
+}
+
+func (rbc *RecordBackgroundChanger) GetSupportedFormats() []string {
+	return rbc.formats
+}
+
+// recordOutputs reads the outputs to pretend there are, either names or
+// objects with name, width, height, x and y.
+func recordOutputs(value interface{}) []Output {
+	items, _ := value.([]interface{})
+	var outputs []Output
+	for _, item := range items {
+		switch output := item.(type) {
+		case string:
+			outputs = append(outputs, Output{Name: output})
+		case map[string]interface{}:
+			name, _ := output["name"].(string)
+			if name == "" {
+				logger.Warningf("Record output %v has no name, skipping it", output)
+				continue
+			}
+			number := func(key string) int {
+				value, _ := output[key].(float64)
+				return int(value)
+			}
+			outputs = append(outputs, Output{
+				Name:   name,
+				Width:  number("width"),
+				Height: number("height"),
+				X:      number("x"),
+				Y:      number("y"),
+			})
+		default:
+			logger.Warningf("Record output %v is neither a name nor an object, skipping it", item)
+		}
+	}
+	return outputs
+}
+
+func RecordDetect(session *Session) Detection {
+	return Rejected("only used when selected in the configuration")
+}
+
+func GetRecordBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	rbc := &RecordBackgroundChanger{
+		formats: []string{"jpeg", "png", "jpg"},
+		outputs: recordOutputs(config["outputs"]),
+	}
+	rbc.file, _ = config["file"].(string)
+	rbc.current, _ = config["current"].(string)
+	if formats, ok := stringList(config["formats"]); ok {
+		rbc.formats = formats
+	}
+	return rbc
+}
+
+func init() {
+	RegisterDE("record", PriorityGeneric, RecordDetect, GetRecordBackgroundChanger)
+}
@@ -0,0 +1,124 @@
+package de
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+// readRecord decodes every line of the record file
+func readRecord(t *testing.T, path string) []RecordEntry {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []RecordEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry RecordEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Line %q is not JSON. %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRecordApply(t *testing.T) {
+	directory := t.TempDir()
+	pictures := filepath.Join(directory, "cache", "linked")
+	if err := os.MkdirAll(pictures, 0755); err != nil {
+		t.Fatal(err)
+	}
+	first, second := filepath.Join(pictures, "a.jpg"), filepath.Join(pictures, "b.jpg")
+	if err := util.WriteMetadata(first, map[string]interface{}{"title": "A", "source": "bing"}); err != nil {
+		t.Fatal(err)
+	}
+	recordFile, current := filepath.Join(directory, "record.jsonl"), filepath.Join(directory, "current")
+	if err := os.Symlink("/pictures/old.jpg", current); err != nil {
+		t.Fatal(err)
+	}
+	rbc := GetRecordBackgroundChanger(map[string]interface{}{"file": recordFile, "current": current})
+
+	for _, picture := range []string{first, second} {
+		if err := rbc.Apply(Wallpaper{Picture: picture}); err != nil {
+			t.Fatal(err)
+		}
+		if target, err := os.Readlink(current); err != nil || target != picture {
+			t.Errorf("Current points at %v instead of %v. %v", target, picture, err)
+		}
+	}
+	if files, _ := ioutil.ReadDir(directory); len(files) != 3 {
+		t.Errorf("Temporary symlinks left behind, %v", files)
+	}
+	entries := readRecord(t, recordFile)
+	if len(entries) != 2 {
+		t.Fatalf("Expected a line per wallpaper, got %v", entries)
+	}
+	if entries[0].Path != first || entries[0].Source != "bing" || entries[0].Metadata["title"] != "A" || entries[0].Time.IsZero() {
+		t.Errorf("Unexpected first entry %+v", entries[0])
+	}
+	if entries[1].Path != second || entries[1].Source != "linked" || entries[1].Metadata != nil {
+		t.Errorf("Unexpected second entry %+v", entries[1])
+	}
+}
+
+func TestRecordJSONLines(t *testing.T) {
+	recordFile := filepath.Join(t.TempDir(), "record.jsonl")
+	rbc := GetRecordBackgroundChanger(map[string]interface{}{"file": recordFile})
+	for _, picture := range []string{"/pictures/a.jpg", "/pictures/b\nc.jpg"} {
+		if err := rbc.Apply(Wallpaper{Picture: picture}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content, err := ioutil.ReadFile(recordFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if len(lines) != 3 || lines[2] != "" {
+		t.Fatalf("Expected two newline terminated lines, got %q", content)
+	}
+	for _, line := range lines[:2] {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Errorf("Line %q is not a JSON object. %v", line, err)
+		}
+		if _, ok := entry["output"]; ok {
+			t.Errorf("Line %q has an output without outputs configured", line)
+		}
+	}
+}
+
+func TestRecordOutputs(t *testing.T) {
+	recordFile := filepath.Join(t.TempDir(), "record.jsonl")
+	rbc := GetRecordBackgroundChanger(map[string]interface{}{
+		"file": recordFile,
+		"outputs": []interface{}{
+			"eDP-1",
+			map[string]interface{}{"name": "HDMI-1", "width": 2560.0, "height": 1440.0, "x": 1920.0},
+			map[string]interface{}{"width": 1.0},
+		},
+	}).(*RecordBackgroundChanger)
+	outputs, err := rbc.GetOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 || outputs[1] != (Output{Name: "HDMI-1", Width: 2560, Height: 1440, X: 1920}) {
+		t.Errorf("Unexpected outputs %v", outputs)
+	}
+	if err := rbc.Apply(Wallpaper{Picture: "/pictures/a.jpg", Outputs: map[string]string{"HDMI-1": "/pictures/b.jpg"}}); err != nil {
+		t.Fatal(err)
+	}
+	entries := readRecord(t, recordFile)
+	if len(entries) != 2 || entries[0].Output != "eDP-1" || entries[0].Path != "/pictures/a.jpg" || entries[1].Output != "HDMI-1" || entries[1].Path != "/pictures/b.jpg" {
+		t.Errorf("Expected a line per output, got %+v", entries)
+	}
+}
@@ -0,0 +1,170 @@
+package de
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Weight of each kind of evidence about the running desktop environment.
+// The first XDG_CURRENT_DESKTOP entry wins over later ones, which is how
+// Budgie:GNOME ends up with budgie ahead of gnome-shell.
+const (
+	ScoreConfigured       = 1000
+	ScoreCurrentDesktop   = 100
+	ScoreFallbackDesktop  = 90
+	ScoreDesktopSession   = 80
+	ScoreProcess          = 60
+	ScoreSocket           = 50
+	ScoreEnvironment      = 40
+	ScoreDisplayAvailable = 10
+)
+
+// Detection is the outcome of checking a desktop environment against the
+// running session, with the reasons that led to it.
+type Detection struct {
+	Score   int
+	Reasons []string
+}
+
+func (d *Detection) Add(score int, format string, args ...interface{}) {
+	if score <= 0 {
+		return
+	}
+	d.Score += score
+	d.Reasons = append(d.Reasons, fmt.Sprintf(format, args...))
+}
+
+func (d Detection) Accepted() bool {
+	return d.Score > 0
+}
+
+func (d Detection) String() string {
+	if len(d.Reasons) == 0 {
+		return "no evidence found in the session"
+	}
+	return strings.Join(d.Reasons, ", ")
+}
+
+func Rejected(format string, args ...interface{}) Detection {
+	return Detection{Reasons: []string{fmt.Sprintf(format, args...)}}
+}
+
+// Session is a snapshot of the environment desktop environments are
+// detected from, taken once so every candidate sees the same thing.
+type Session struct {
+	// Config is what the desktop environment would be set up with
+	Config map[string]interface{}
+
+	desktops       []string
+	desktopSession string
+	processes      map[string]bool
+}
+
+var listProcesses = func() []string {
+	var processes []string
+	commFiles, _ := filepath.Glob("/proc/[0-9]*/comm")
+	for _, commFile := range commFiles {
+		if comm, err := ioutil.ReadFile(commFile); err == nil {
+			processes = append(processes, strings.TrimSpace(string(comm)))
+		}
+	}
+	return processes
+}
+
+var statPath = os.Stat
+
+func NewSession(config map[string]interface{}) *Session {
+	session := &Session{
+		Config:         config,
+		desktopSession: strings.ToLower(getenv("DESKTOP_SESSION")),
+		processes:      make(map[string]bool),
+	}
+	for _, desktop := range strings.Split(getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if desktop = strings.ToLower(strings.TrimSpace(desktop)); desktop != "" {
+			session.desktops = append(session.desktops, desktop)
+		}
+	}
+	for _, process := range listProcesses() {
+		session.processes[process] = true
+	}
+	return session
+}
+
+func (s *Session) Getenv(name string) string {
+	return getenv(name)
+}
+
+// DesktopScore matches names against XDG_CURRENT_DESKTOP
+func (s *Session) DesktopScore(names ...string) int {
+	for index, desktop := range s.desktops {
+		for _, name := range names {
+			if desktop != name {
+				continue
+			}
+			if index == 0 {
+				return ScoreCurrentDesktop
+			}
+			return ScoreFallbackDesktop
+		}
+	}
+	return 0
+}
+
+func (s *Session) IsDesktop(names ...string) bool {
+	return s.DesktopScore(names...) > 0
+}
+
+func (s *Session) HasDesktop() bool {
+	return len(s.desktops) > 0
+}
+
+// SessionScore looks for any of the names in DESKTOP_SESSION
+func (s *Session) SessionScore(names ...string) int {
+	if s.desktopSession == "" {
+		return 0
+	}
+	for _, name := range names {
+		if strings.Contains(s.desktopSession, name) {
+			return ScoreDesktopSession
+		}
+	}
+	return 0
+}
+
+func (s *Session) IsRunning(names ...string) bool {
+	for _, name := range names {
+		if s.processes[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Session) ProcessScore(names ...string) int {
+	if s.IsRunning(names...) {
+		return ScoreProcess
+	}
+	return 0
+}
+
+func (s *Session) HasSocket(path string) bool {
+	info, err := statPath(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+func (s *Session) SocketScore(path string) int {
+	if path != "" && s.HasSocket(path) {
+		return ScoreSocket
+	}
+	return 0
+}
+
+func (s *Session) EnvironmentScore(name string) int {
+	if getenv(name) != "" {
+		return ScoreEnvironment
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package de
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+type SwayBackgroundChanger struct{}
+
+func (sbc *SwayBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	if len(wallpaper.Outputs) == 0 {
+		return swaySetBackground("*", wallpaper.Picture)
+	}
+	outputs, err := sbc.GetOutputs()
+	if err != nil {
+		return err
+	}
+	for _, output := range outputs {
+		if err := swaySetBackground(output.Name, wallpaper.GetPicture(output.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func swaySetBackground(output, picture string) error {
+	commandOutput, err := runCommand("swaymsg", "output", swayQuote(output), "bg", swayQuote(picture), "fill")
+	if err != nil {
+		return Retryable(fmt.Errorf("swaymsg failed: %v %s", err, commandOutput))
+	}
+	return nil
+}
+
+func (sbc *SwayBackgroundChanger) GetOutputs() ([]Output, error) {
+	commandOutput, err := runCommand("swaymsg", "--raw", "--type", "get_outputs")
+	if err != nil {
+		return nil, Retryable(fmt.Errorf("swaymsg failed: %v %s", err, commandOutput))
+	}
+	var swayOutputs []struct {
+		Name   string
+		Active bool
+		Rect   struct {
+			X      int
+			Y      int
+			Width  int
+			Height int
+		}
+	}
+	if err := json.Unmarshal(commandOutput, &swayOutputs); err != nil {
+		return nil, fmt.Errorf("swaymsg outputs could not be read: %v", err)
+	}
+	var outputs []Output
+	for _, swayOutput := range swayOutputs {
+		if !swayOutput.Active {
+			continue
+		}
+		outputs = append(outputs, Output{
+			Name:   swayOutput.Name,
+			Width:  swayOutput.Rect.Width,
+			Height: swayOutput.Rect.Height,
+			X:      swayOutput.Rect.X,
+			Y:      swayOutput.Rect.Y,
+		})
+	}
+	return outputs, nil
+}
+
+func (sbc *SwayBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+// swayQuote protects the path from sway's command parser, which splits the
+// arguments swaymsg sends on whitespace.
+func swayQuote(argument string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(argument) + `"`
+}
+
+func SwayDetect(session *Session) Detection {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return Rejected("only available on linux and freebsd")
+	}
+	var detection Detection
+	detection.Add(session.DesktopScore("sway"), "XDG_CURRENT_DESKTOP is %v", session.Getenv("XDG_CURRENT_DESKTOP"))
+	detection.Add(session.SocketScore(session.Getenv("SWAYSOCK")), "SWAYSOCK %v is a socket", session.Getenv("SWAYSOCK"))
+	detection.Add(session.ProcessScore("sway"), "sway is running")
+	return detection
+}
+
+func GetSwayBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &SwayBackgroundChanger{}
+}
+
+func init() {
+	RegisterDE("sway", PrioritySpecific, SwayDetect, GetSwayBackgroundChanger)
+}
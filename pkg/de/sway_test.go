@@ -0,0 +1,78 @@
+package de
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSwayDetect(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("sway is only detected on linux and freebsd")
+	}
+	stubSession(t, map[string]string{"SWAYSOCK": "/run/user/1000/sway-ipc.sock"})
+	stubSockets(t, "/run/user/1000/sway-ipc.sock")
+	if detection := SwayDetect(NewSession(nil)); detection.Score != ScoreSocket {
+		t.Errorf("sway not detected with SWAYSOCK, %v", detection)
+	}
+	// A SWAYSOCK left behind by a sway that is gone is no evidence
+	stubSockets(t)
+	if detection := SwayDetect(NewSession(nil)); detection.Accepted() {
+		t.Errorf("sway detected with a stale SWAYSOCK, %v", detection)
+	}
+	stubSession(t, map[string]string{"XDG_CURRENT_DESKTOP": "Hyprland", "WAYLAND_DISPLAY": "wayland-1"}, "Hyprland")
+	if detection := SwayDetect(NewSession(nil)); detection.Accepted() {
+		t.Errorf("sway detected in Hyprland, %v", detection)
+	}
+}
+
+func TestSwayApply(t *testing.T) {
+	commands := stubCommands(t, nil)
+	if err := (&SwayBackgroundChanger{}).Apply(Wallpaper{Picture: `/pictures/a "b".jpg`}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"swaymsg", "output", swayQuote("*"), "bg", `"/pictures/a \"b\".jpg"`, "fill"}
+	if len(*commands) != 1 || !reflect.DeepEqual((*commands)[0], expected) {
+		t.Errorf("Expected %q, got %q", expected, *commands)
+	}
+}
+
+const testSwayOutputs = `[
+	{"name": "eDP-1", "active": true, "rect": {"x": 0, "y": 0, "width": 1920, "height": 1080}},
+	{"name": "HDMI-A-1", "active": true, "rect": {"x": 1920, "y": 0, "width": 2560, "height": 1440}},
+	{"name": "DP-2", "active": false, "rect": {"x": 0, "y": 0, "width": 0, "height": 0}}
+]`
+
+func TestSwayApplyPerOutput(t *testing.T) {
+	commands := stubRunner(t, func(command []string) ([]byte, error) {
+		if command[1] == "--raw" {
+			return []byte(testSwayOutputs), nil
+		}
+		return nil, nil
+	})
+	sbc := &SwayBackgroundChanger{}
+	outputs, err := sbc.GetOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []Output{
+		{Name: "eDP-1", Width: 1920, Height: 1080},
+		{Name: "HDMI-A-1", Width: 2560, Height: 1440, X: 1920},
+	}; !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("Expected outputs %v, got %v", expected, outputs)
+	}
+
+	*commands = nil
+	wallpaper := Wallpaper{Picture: "/pictures/a.jpg", Outputs: map[string]string{"HDMI-A-1": "/pictures/b.jpg"}}
+	if err := sbc.Apply(wallpaper); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"swaymsg", "--raw", "--type", "get_outputs"},
+		{"swaymsg", "output", swayQuote("eDP-1"), "bg", swayQuote("/pictures/a.jpg"), "fill"},
+		{"swaymsg", "output", swayQuote("HDMI-A-1"), "bg", swayQuote("/pictures/b.jpg"), "fill"},
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, *commands)
+	}
+}
@@ -0,0 +1,184 @@
+package de
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Time given to a new swaybg to draw before the previous one is stopped, so
+// the change doesn't flicker through an empty background. A new swaybg
+// exiting meanwhile fails the change.
+var swaybgHandover = 500 * time.Millisecond
+
+// A swaybg exiting unexpectedly is restarted after swaybgRestartDelay,
+// doubled on each restart in a row up to swaybgMaxRestartDelay. One that
+// ran for longer than the maximum starts over from the first delay.
+var (
+	swaybgRestartDelay    = 5 * time.Second
+	swaybgMaxRestartDelay = 5 * time.Minute
+)
+
+// SwaybgBackgroundChanger supervises a swaybg process, used on wlroots
+// compositors that have no wallpaper support of their own.
+type SwaybgBackgroundChanger struct {
+	mutex    sync.Mutex
+	process  *swaybgProcess
+	picture  string
+	restarts int
+	closed   bool
+}
+
+type swaybgProcess struct {
+	command *exec.Cmd
+	started time.Time
+	// exited is closed once the process is gone, err tells why
+	exited chan struct{}
+	err    error
+}
+
+func (sbc *SwaybgBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	sbc.mutex.Lock()
+	if sbc.closed {
+		sbc.mutex.Unlock()
+		return errors.New("swaybg is no longer supervised")
+	}
+	previous, previousPicture := sbc.process, sbc.picture
+	sbc.picture = wallpaper.Picture
+	sbc.restarts = 0
+	if err := sbc.start(); err != nil {
+		sbc.process, sbc.picture = previous, previousPicture
+		sbc.mutex.Unlock()
+		return err
+	}
+	current := sbc.process
+	sbc.mutex.Unlock()
+
+	select {
+	case <-current.exited:
+		// The previous swaybg is kept drawing, unless it was replaced by a
+		// restart meanwhile
+		sbc.mutex.Lock()
+		defer sbc.mutex.Unlock()
+		if sbc.process == nil && previous != nil {
+			sbc.process, sbc.picture = previous, previousPicture
+		}
+		return Retryable(fmt.Errorf("swaybg exited right after starting with %v. %v", wallpaper.Picture, current.err))
+	case <-time.After(swaybgHandover):
+	}
+	if previous != nil {
+		previous.command.Process.Kill()
+	}
+	return nil
+}
+
+// start has to be called with the mutex held
+func (sbc *SwaybgBackgroundChanger) start() error {
+	command, err := startCommand("swaybg", "--mode", "fill", "--image", sbc.picture)
+	if err != nil {
+		return Retryable(err)
+	}
+	sbc.process = &swaybgProcess{command: command, started: time.Now(), exited: make(chan struct{})}
+	go sbc.supervise(sbc.process)
+	return nil
+}
+
+func (sbc *SwaybgBackgroundChanger) supervise(process *swaybgProcess) {
+	err := process.command.Wait()
+
+	sbc.mutex.Lock()
+	defer sbc.mutex.Unlock()
+	process.err = err
+	close(process.exited)
+	if sbc.process != process || sbc.closed {
+		logger.Tracef("Replaced or released swaybg exited")
+		return
+	}
+	if time.Since(process.started) > swaybgMaxRestartDelay {
+		sbc.restarts = 0
+	}
+	logger.Warningf("swaybg exited unexpectedly. %v", err)
+	sbc.process = nil
+	sbc.restartLater()
+}
+
+// swaybgRestartBackoff is the delay before restarting swaybg after a number
+// of restarts in a row
+func swaybgRestartBackoff(restarts int) time.Duration {
+	delay := swaybgRestartDelay
+	for restart := 0; restart < restarts && delay < swaybgMaxRestartDelay; restart++ {
+		delay *= 2
+	}
+	if delay > swaybgMaxRestartDelay {
+		delay = swaybgMaxRestartDelay
+	}
+	return delay
+}
+
+// restartLater has to be called with the mutex held
+func (sbc *SwaybgBackgroundChanger) restartLater() {
+	delay := swaybgRestartBackoff(sbc.restarts)
+	sbc.restarts++
+	logger.Infof("Restarting swaybg in %v", delay)
+	go func() {
+		time.Sleep(delay)
+		sbc.mutex.Lock()
+		defer sbc.mutex.Unlock()
+		if sbc.process != nil || sbc.closed {
+			return
+		}
+		if err := sbc.start(); err != nil {
+			logger.Errorf("Failed to restart swaybg. %v", err)
+			sbc.restartLater()
+		}
+	}()
+}
+
+// Close stops supervising swaybg. The running one is left alone so the
+// background stays after sawyer exits.
+func (sbc *SwaybgBackgroundChanger) Close() error {
+	sbc.mutex.Lock()
+	defer sbc.mutex.Unlock()
+	sbc.closed = true
+	return nil
+}
+
+func (sbc *SwaybgBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+// Desktop environments drawing their own background, where a swaybg
+// layer would be hidden or fight with them. Hyprland isn't one of them,
+// swaybg draws fine there and is what is used when hyprpaper isn't
+// running, as hyprpaper is detected with a higher priority otherwise.
+var swaybgManagedDesktops = []string{"gnome", "kde", "xfce", "x-cinnamon", "mate", "budgie", "lxqt"}
+
+func SwaybgDetect(session *Session) Detection {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return Rejected("only available on linux and freebsd")
+	}
+	if session.Getenv("WAYLAND_DISPLAY") == "" {
+		return Rejected("WAYLAND_DISPLAY is not set")
+	}
+	if session.IsDesktop(swaybgManagedDesktops...) {
+		return Rejected("%v draws its own background", session.Getenv("XDG_CURRENT_DESKTOP"))
+	}
+	if _, err := lookPath("swaybg"); err != nil {
+		return Rejected("swaybg is not installed")
+	}
+	var detection Detection
+	detection.Add(ScoreDisplayAvailable, "WAYLAND_DISPLAY is %v", session.Getenv("WAYLAND_DISPLAY"))
+	detection.Add(session.ProcessScore("swaybg"), "swaybg is running")
+	return detection
+}
+
+func GetSwaybgBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &SwaybgBackgroundChanger{}
+}
+
+func init() {
+	RegisterDE("swaybg", PriorityGeneric, SwaybgDetect, GetSwaybgBackgroundChanger)
+}
@@ -0,0 +1,228 @@
+package de
+
+import (
+	"os/exec"
+	"reflect"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stubSwaybg starts a process running command instead of swaybg, recording
+// the pictures it was started with
+func stubSwaybg(t *testing.T, command ...string) func() []string {
+	var mutex sync.Mutex
+	var pictures []string
+	var processes []*exec.Cmd
+	original := startCommand
+	t.Cleanup(func() {
+		startCommand = original
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, process := range processes {
+			process.Process.Kill()
+		}
+	})
+	startCommand = func(name string, args ...string) (*exec.Cmd, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pictures = append(pictures, args[len(args)-1])
+		process := exec.Command(command[0], command[1:]...)
+		processes = append(processes, process)
+		return process, process.Start()
+	}
+	return func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string(nil), pictures...)
+	}
+}
+
+// newSwaybg returns a background changer whose swaybg isn't restarted
+// anymore once the test is over
+func newSwaybg(t *testing.T) *SwaybgBackgroundChanger {
+	sbc := &SwaybgBackgroundChanger{}
+	t.Cleanup(func() { sbc.Close() })
+	return sbc
+}
+
+// stubSwaybgTimes sets the handover and restart delays for the test
+func stubSwaybgTimes(t *testing.T, handover, restart, maxRestart time.Duration) {
+	originalHandover, originalRestart, originalMax := swaybgHandover, swaybgRestartDelay, swaybgMaxRestartDelay
+	t.Cleanup(func() {
+		swaybgHandover, swaybgRestartDelay, swaybgMaxRestartDelay = originalHandover, originalRestart, originalMax
+	})
+	swaybgHandover, swaybgRestartDelay, swaybgMaxRestartDelay = handover, restart, maxRestart
+}
+
+// eventually waits for condition to hold, failing after a second
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+	}
+}
+
+func TestSwaybgApply(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is needed to stand for swaybg")
+	}
+	stubSwaybgTimes(t, time.Millisecond, time.Minute, time.Minute)
+	started := stubSwaybg(t, "sleep", "60")
+
+	sbc := newSwaybg(t)
+	if err := sbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	first := sbc.process
+	if err := sbc.Apply(Wallpaper{Picture: "/pictures/b.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	if pictures := started(); !reflect.DeepEqual(pictures, []string{"/pictures/a.jpg", "/pictures/b.jpg"}) {
+		t.Errorf("Unexpected swaybg started with %v", pictures)
+	}
+	// The previous swaybg is stopped once the new one took over
+	eventually(t, func() bool { return first.command.Process.Signal(syscall.Signal(0)) != nil })
+}
+
+func TestSwaybgApplyFailing(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is needed to stand for swaybg")
+	}
+	stubSwaybgTimes(t, 200*time.Millisecond, time.Minute, time.Minute)
+	stubSwaybg(t, "sleep", "60")
+	sbc := newSwaybg(t)
+	if err := sbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	first := sbc.process
+
+	// swaybg refusing the picture fails the change, keeping the previous one
+	stubSwaybg(t, "false")
+	err := sbc.Apply(Wallpaper{Picture: "/pictures/broken.jpg"})
+	if err == nil || !IsRetryable(err) {
+		t.Errorf("Expected a retryable error, got %v", err)
+	}
+	if sbc.process != first || sbc.picture != "/pictures/a.jpg" {
+		t.Errorf("Previous swaybg not kept, drawing %v", sbc.picture)
+	}
+	if first.command.Process.Signal(syscall.Signal(0)) != nil {
+		t.Errorf("Previous swaybg was stopped")
+	}
+}
+
+func TestSwaybgHandoverUnlocked(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is needed to stand for swaybg")
+	}
+	stubSwaybgTimes(t, 300*time.Millisecond, time.Minute, time.Minute)
+	stubSwaybg(t, "sleep", "60")
+	sbc := newSwaybg(t)
+	if err := sbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	applied := make(chan error)
+	go func() { applied <- sbc.Apply(Wallpaper{Picture: "/pictures/b.jpg"}) }()
+	eventually(t, func() bool {
+		sbc.mutex.Lock()
+		defer sbc.mutex.Unlock()
+		return sbc.picture == "/pictures/b.jpg"
+	})
+	// Closing doesn't wait for the new swaybg to take over
+	start := time.Now()
+	Close(sbc)
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Closing waited %v for the handover", elapsed)
+	}
+	if err := <-applied; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSwaybgRestartBackoff(t *testing.T) {
+	stubSwaybgTimes(t, time.Millisecond, 5*time.Second, time.Minute)
+	for restarts, expected := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute} {
+		if delay := swaybgRestartBackoff(restarts); delay != expected {
+			t.Errorf("Restart after %v restarts in %v, expected %v", restarts, delay, expected)
+		}
+	}
+	if delay := swaybgRestartBackoff(1000); delay != time.Minute {
+		t.Errorf("Restart after many restarts in %v", delay)
+	}
+}
+
+func TestSwaybgRestart(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is needed to stand for a crashing swaybg")
+	}
+	stubSwaybgTimes(t, time.Millisecond, time.Millisecond, 4*time.Millisecond)
+	started := stubSwaybg(t, "sleep", "0.05")
+
+	sbc := newSwaybg(t)
+	if err := sbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return len(started()) >= 3 })
+	for _, picture := range started() {
+		if picture != "/pictures/a.jpg" {
+			t.Errorf("swaybg restarted with %v", picture)
+		}
+	}
+}
+
+func TestSwaybgClose(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is needed to stand for a crashing swaybg")
+	}
+	stubSwaybgTimes(t, time.Millisecond, 10*time.Millisecond, 10*time.Millisecond)
+	started := stubSwaybg(t, "sleep", "0.01")
+
+	sbc := newSwaybg(t)
+	if err := sbc.Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(sbc); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	restarts := len(started())
+	time.Sleep(50 * time.Millisecond)
+	if len(started()) != restarts {
+		t.Errorf("swaybg still restarted after being closed")
+	}
+	if err := sbc.Apply(Wallpaper{Picture: "/pictures/b.jpg"}); err == nil {
+		t.Errorf("Applied after being closed")
+	}
+	if err := Close(&fakeBackgroundChanger{}); err != nil {
+		t.Errorf("Closing a backend without anything running failed, %v", err)
+	}
+}
+
+func TestSwaybgDetect(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("swaybg is only detected on linux and freebsd")
+	}
+	stubInstalled(t, "swaybg")
+	stubSession(t, map[string]string{"WAYLAND_DISPLAY": "wayland-1", "XDG_CURRENT_DESKTOP": "river"}, "river")
+	if detection := SwaybgDetect(NewSession(nil)); detection.Score != ScoreDisplayAvailable {
+		t.Errorf("swaybg not used in a wlroots compositor, %v", detection)
+	}
+	// Hyprland without hyprpaper running falls back to swaybg
+	stubSession(t, map[string]string{"WAYLAND_DISPLAY": "wayland-1", "XDG_CURRENT_DESKTOP": "Hyprland"}, "Hyprland")
+	if detection := SwaybgDetect(NewSession(nil)); !detection.Accepted() {
+		t.Errorf("swaybg not used in Hyprland, %v", detection)
+	}
+	stubSession(t, map[string]string{"WAYLAND_DISPLAY": "wayland-0", "XDG_CURRENT_DESKTOP": "GNOME"}, "gnome-shell")
+	if detection := SwaybgDetect(NewSession(nil)); detection.Accepted() {
+		t.Errorf("swaybg used in GNOME, %v", detection)
+	}
+	stubInstalled(t)
+	stubSession(t, map[string]string{"WAYLAND_DISPLAY": "wayland-1"})
+	if detection := SwaybgDetect(NewSession(nil)); detection.Accepted() {
+		t.Errorf("swaybg used without being installed, %v", detection)
+	}
+}
@@ -0,0 +1,119 @@
+package de
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Programs able to set the root window pixmap, including _XROOTPMAP_ID and
+// ESETROOT_PMAP_ID so compositors and pseudo transparent terminals follow.
+// They are tried in order and the first one installed is used. Those that
+// can set a picture per output have outputArgs.
+type x11Setter struct {
+	name       string
+	args       func(picture string) []string
+	outputArgs func(outputs []Output, wallpaper Wallpaper) []string
+}
+
+var x11Setters = []x11Setter{
+	{
+		name: "feh",
+		args: func(picture string) []string { return []string{"--no-fehbg", "--bg-fill", picture} },
+		// feh assigns pictures to Xinerama screens in the order given
+		outputArgs: func(outputs []Output, wallpaper Wallpaper) []string {
+			args := []string{"--no-fehbg", "--bg-fill"}
+			for _, output := range outputs {
+				args = append(args, wallpaper.GetPicture(output.Name))
+			}
+			return args
+		},
+	},
+	{
+		name: "xwallpaper",
+		args: func(picture string) []string { return []string{"--zoom", picture} },
+		outputArgs: func(outputs []Output, wallpaper Wallpaper) []string {
+			var args []string
+			for _, output := range outputs {
+				args = append(args, "--output", output.Name, "--zoom", wallpaper.GetPicture(output.Name))
+			}
+			return args
+		},
+	},
+	{
+		name: "hsetroot",
+		args: func(picture string) []string { return []string{"-fill", picture} },
+	},
+}
+
+// Desktop environments that draw their own background on top of the root
+// window, where setting it would have no visible effect.
+var x11ManagedDesktops = []string{
+	"gnome", "unity", "kde", "xfce", "x-cinnamon", "cinnamon", "mate", "budgie",
+	"lxqt", "lxde", "pantheon", "deepin", "enlightenment",
+}
+
+type X11BackgroundChanger struct {
+	setter x11Setter
+}
+
+func (xbc *X11BackgroundChanger) Apply(wallpaper Wallpaper) error {
+	args := xbc.setter.args(wallpaper.Picture)
+	if len(wallpaper.Outputs) > 0 {
+		outputs, err := xbc.GetOutputs()
+		if err != nil {
+			return err
+		}
+		args = xbc.setter.outputArgs(outputs, wallpaper)
+	}
+	output, err := runCommand(xbc.setter.name, args...)
+	if err != nil {
+		return Retryable(fmt.Errorf("%v failed: %v %s", xbc.setter.name, err, output))
+	}
+	return nil
+}
+
+func (xbc *X11BackgroundChanger) GetOutputs() ([]Output, error) {
+	if xbc.setter.outputArgs == nil {
+		return nil, ErrOutputsUnsupported
+	}
+	return xrandrOutputs()
+}
+
+func (xbc *X11BackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+func X11Detect(session *Session) Detection {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" && runtime.GOOS != "openbsd" {
+		return Rejected("only available on linux and bsd")
+	}
+	if session.Getenv("DISPLAY") == "" {
+		return Rejected("DISPLAY is not set")
+	}
+	if session.Getenv("WAYLAND_DISPLAY") != "" {
+		return Rejected("session is Wayland")
+	}
+	if session.IsDesktop(x11ManagedDesktops...) {
+		return Rejected("%v draws its own background", session.Getenv("XDG_CURRENT_DESKTOP"))
+	}
+	if GetX11BackgroundChanger(nil) == nil {
+		return Rejected("none of feh, xwallpaper or hsetroot is installed")
+	}
+	var detection Detection
+	detection.Add(ScoreDisplayAvailable, "DISPLAY is %v", session.Getenv("DISPLAY"))
+	return detection
+}
+
+func GetX11BackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	for _, setter := range x11Setters {
+		if _, err := lookPath(setter.name); err == nil {
+			logger.Debugf("Using %v to set the root window", setter.name)
+			return &X11BackgroundChanger{setter: setter}
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterDE("x11", PriorityGeneric, X11Detect, GetX11BackgroundChanger)
+}
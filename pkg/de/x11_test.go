@@ -0,0 +1,137 @@
+package de
+
+import (
+	"bufio"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestX11Detect(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("X11 detection is only tested on linux and freebsd")
+	}
+	stubSession(t, map[string]string{"DISPLAY": ":0", "XDG_CURRENT_DESKTOP": "i3"}, "i3")
+	stubInstalled(t, "xwallpaper", "hsetroot")
+	commands := stubCommands(t, nil)
+	if detection := X11Detect(NewSession(nil)); detection.Score != ScoreDisplayAvailable {
+		t.Fatalf("X11 not detected in i3, %v", detection)
+	}
+	if err := GetX11BackgroundChanger(nil).(*X11BackgroundChanger).Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"xwallpaper", "--zoom", "/pictures/a.jpg"}; len(*commands) != 1 || !reflect.DeepEqual((*commands)[0], expected) {
+		t.Errorf("Expected %q, got %q", expected, *commands)
+	}
+
+	for _, environment := range []map[string]string{
+		{"DISPLAY": ":0", "XDG_CURRENT_DESKTOP": "XFCE"},
+		{"DISPLAY": ":0", "WAYLAND_DISPLAY": "wayland-0"},
+		{"XDG_CURRENT_DESKTOP": "i3"},
+	} {
+		stubSession(t, environment)
+		if detection := X11Detect(NewSession(nil)); detection.Accepted() {
+			t.Errorf("X11 detected with %v, %v", environment, detection)
+		}
+	}
+	stubSession(t, map[string]string{"DISPLAY": ":0"})
+	stubInstalled(t)
+	if detection := X11Detect(NewSession(nil)); detection.Accepted() {
+		t.Errorf("X11 detected without any setter installed, %v", detection)
+	}
+}
+
+func TestX11ApplyPerOutput(t *testing.T) {
+	wallpaper := Wallpaper{Picture: "/pictures/a.jpg", Outputs: map[string]string{"HDMI-1": "/pictures/b.jpg"}}
+	for setter, expected := range map[string][]string{
+		"feh":        {"feh", "--no-fehbg", "--bg-fill", "/pictures/a.jpg", "/pictures/b.jpg"},
+		"xwallpaper": {"xwallpaper", "--output", "eDP-1", "--zoom", "/pictures/a.jpg", "--output", "HDMI-1", "--zoom", "/pictures/b.jpg"},
+	} {
+		stubInstalled(t, setter)
+		commands := stubRunner(t, func(command []string) ([]byte, error) {
+			if command[0] == "xrandr" {
+				return []byte(testXrandrMonitors), nil
+			}
+			return nil, nil
+		})
+		if err := GetX11BackgroundChanger(nil).(*X11BackgroundChanger).Apply(wallpaper); err != nil {
+			t.Fatal(err)
+		}
+		if len(*commands) != 2 || !reflect.DeepEqual((*commands)[1], expected) {
+			t.Errorf("Expected %q, got %q", expected, *commands)
+		}
+	}
+
+	stubInstalled(t, "hsetroot")
+	if _, err := GetX11BackgroundChanger(nil).(*X11BackgroundChanger).GetOutputs(); err != ErrOutputsUnsupported {
+		t.Errorf("Expected hsetroot to not support outputs, got %v", err)
+	}
+}
+
+// startXvfb runs a virtual X server for the test, returning its display
+func startXvfb(t *testing.T) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	xvfb := exec.Command("Xvfb", "-displayfd", "3", "-screen", "0", "320x240x24", "-nolisten", "tcp")
+	xvfb.ExtraFiles = []*os.File{writer}
+	if err := xvfb.Start(); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	t.Cleanup(func() {
+		xvfb.Process.Kill()
+		xvfb.Wait()
+	})
+	display, err := bufio.NewReader(reader).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Xvfb didn't tell its display. %v", err)
+	}
+	return ":" + strings.TrimSpace(display)
+}
+
+func TestX11Xvfb(t *testing.T) {
+	for _, program := range []string{"Xvfb", "xprop"} {
+		if _, err := exec.LookPath(program); err != nil {
+			t.Skipf("%v is needed to test against a real X server", program)
+		}
+	}
+	originalDisplay, hadDisplay := os.LookupEnv("DISPLAY")
+	t.Cleanup(func() {
+		if hadDisplay {
+			os.Setenv("DISPLAY", originalDisplay)
+		} else {
+			os.Unsetenv("DISPLAY")
+		}
+	})
+	os.Setenv("DISPLAY", startXvfb(t))
+	backgroundChanger := GetX11BackgroundChanger(nil)
+	if backgroundChanger == nil {
+		t.Skip("None of feh, xwallpaper or hsetroot is installed")
+	}
+
+	picture := filepath.Join(t.TempDir(), "picture.png")
+	file, err := os.Create(picture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(file, image.NewRGBA(image.Rect(0, 0, 32, 24)))
+	file.Close()
+	if err := backgroundChanger.(*X11BackgroundChanger).Apply(Wallpaper{Picture: picture}); err != nil {
+		t.Fatal(err)
+	}
+	for _, property := range []string{"_XROOTPMAP_ID", "ESETROOT_PMAP_ID"} {
+		output, err := exec.Command("xprop", "-root", property).CombinedOutput()
+		if err != nil || !strings.Contains(string(output), "pixmap id") {
+			t.Errorf("Root window %v not set. %v %s", property, err, output)
+		}
+	}
+}
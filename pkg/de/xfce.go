@@ -0,0 +1,110 @@
+package de
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// XfceBackgroundChanger sets the picture on every monitor and workspace
+// xfdesktop knows about, each has its own last-image property.
+type XfceBackgroundChanger struct{}
+
+func (xbc *XfceBackgroundChanger) Apply(wallpaper Wallpaper) error {
+	properties, err := xfceBackdropProperties()
+	if err != nil {
+		return err
+	}
+	if len(properties) == 0 {
+		return fmt.Errorf("no xfdesktop backdrop properties found")
+	}
+	for _, property := range properties {
+		picture := wallpaper.GetPicture(xfceMonitor(property))
+		output, err := runCommand("xfconf-query", "--channel", "xfce4-desktop", "--property", property, "--set", picture)
+		if err != nil {
+			return Retryable(fmt.Errorf("xfconf-query failed setting %v: %v %s", property, err, output))
+		}
+	}
+	return nil
+}
+
+// xfceMonitor extracts the output name from a backdrop property like
+// /backdrop/screen0/monitorHDMI-1/workspace0/last-image
+func xfceMonitor(property string) string {
+	for _, part := range strings.Split(property, "/") {
+		if strings.HasPrefix(part, "monitor") {
+			return strings.TrimPrefix(part, "monitor")
+		}
+	}
+	return ""
+}
+
+// GetOutputs takes the monitors xfdesktop has backdrops for, the layout is
+// only known when xrandr can tell it.
+func (xbc *XfceBackgroundChanger) GetOutputs() ([]Output, error) {
+	properties, err := xfceBackdropProperties()
+	if err != nil {
+		return nil, err
+	}
+	layout := make(map[string]Output)
+	if xrandrOutputs, err := xrandrOutputs(); err == nil {
+		for _, output := range xrandrOutputs {
+			layout[output.Name] = output
+		}
+	} else {
+		logger.Debugf("Output layout not available. %v", err)
+	}
+	var outputs []Output
+	seen := make(map[string]bool)
+	for _, property := range properties {
+		monitor := xfceMonitor(property)
+		if monitor == "" || seen[monitor] {
+			continue
+		}
+		seen[monitor] = true
+		output, ok := layout[monitor]
+		if !ok {
+			output = Output{Name: monitor}
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+func xfceBackdropProperties() ([]string, error) {
+	output, err := runCommand("xfconf-query", "--channel", "xfce4-desktop", "--list")
+	if err != nil {
+		return nil, Retryable(fmt.Errorf("xfconf-query failed listing properties: %v %s", err, output))
+	}
+	var properties []string
+	for _, property := range strings.Split(string(output), "\n") {
+		property = strings.TrimSpace(property)
+		if strings.HasPrefix(property, "/backdrop/") && strings.HasSuffix(property, "/last-image") {
+			properties = append(properties, property)
+		}
+	}
+	return properties, nil
+}
+
+func (xbc *XfceBackgroundChanger) GetSupportedFormats() []string {
+	return []string{"jpeg", "png", "jpg"}
+}
+
+func XfceDetect(session *Session) Detection {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" && runtime.GOOS != "openbsd" {
+		return Rejected("only available on linux and bsd")
+	}
+	var detection Detection
+	detection.Add(session.DesktopScore("xfce"), "XDG_CURRENT_DESKTOP is %v", session.Getenv("XDG_CURRENT_DESKTOP"))
+	detection.Add(session.SessionScore("xfce"), "DESKTOP_SESSION is %v", session.Getenv("DESKTOP_SESSION"))
+	detection.Add(session.ProcessScore("xfdesktop"), "xfdesktop is running")
+	return detection
+}
+
+func GetXfceBackgroundChanger(config map[string]interface{}) DEBackgroundChanger {
+	return &XfceBackgroundChanger{}
+}
+
+func init() {
+	RegisterDE("xfce", PriorityDesktop, XfceDetect, GetXfceBackgroundChanger)
+}
@@ -0,0 +1,91 @@
+package de
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testXfceProperties = `/backdrop/screen0/monitorHDMI-1/workspace0/color-style
+/backdrop/screen0/monitorHDMI-1/workspace0/last-image
+/backdrop/screen0/monitoreDP-1/workspace0/last-image
+/backdrop/screen0/monitoreDP-1/workspace1/last-image
+/desktop-icons/style
+`
+
+func TestXfceApply(t *testing.T) {
+	commands := stubRunner(t, func(command []string) ([]byte, error) {
+		if command[len(command)-1] == "--list" {
+			return []byte(testXfceProperties), nil
+		}
+		return nil, nil
+	})
+	if err := (&XfceBackgroundChanger{}).Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	set := func(property string) []string {
+		return []string{"xfconf-query", "--channel", "xfce4-desktop", "--property", property, "--set", "/pictures/a.jpg"}
+	}
+	expected := [][]string{
+		{"xfconf-query", "--channel", "xfce4-desktop", "--list"},
+		set("/backdrop/screen0/monitorHDMI-1/workspace0/last-image"),
+		set("/backdrop/screen0/monitoreDP-1/workspace0/last-image"),
+		set("/backdrop/screen0/monitoreDP-1/workspace1/last-image"),
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, *commands)
+	}
+
+	stubRunner(t, func(command []string) ([]byte, error) { return []byte("/desktop-icons/style\n"), nil })
+	if err := (&XfceBackgroundChanger{}).Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err == nil {
+		t.Errorf("Applied without any backdrop")
+	}
+	stubCommands(t, errors.New("exit status 1"))
+	if err := (&XfceBackgroundChanger{}).Apply(Wallpaper{Picture: "/pictures/a.jpg"}); err == nil {
+		t.Errorf("Applied without xfconf-query")
+	}
+}
+
+func TestXfceApplyPerOutput(t *testing.T) {
+	commands := stubRunner(t, func(command []string) ([]byte, error) {
+		switch command[0] {
+		case "xrandr":
+			return []byte(testXrandrMonitors), nil
+		case "xfconf-query":
+			if command[len(command)-1] == "--list" {
+				return []byte(testXfceProperties), nil
+			}
+		}
+		return nil, nil
+	})
+	xbc := &XfceBackgroundChanger{}
+	outputs, err := xbc.GetOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// HDMI-1 has its geometry from xrandr, eDP-1 is listed by xfdesktop first
+	if expected := []Output{
+		{Name: "HDMI-1", Width: 2560, Height: 1440, X: 1920, Y: -360},
+		{Name: "eDP-1", Width: 1920, Height: 1080},
+	}; !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("Expected outputs %v, got %v", expected, outputs)
+	}
+
+	*commands = nil
+	wallpaper := Wallpaper{Picture: "/pictures/a.jpg", Outputs: map[string]string{"eDP-1": "/pictures/b.jpg"}}
+	if err := xbc.Apply(wallpaper); err != nil {
+		t.Fatal(err)
+	}
+	set := func(property, picture string) []string {
+		return []string{"xfconf-query", "--channel", "xfce4-desktop", "--property", property, "--set", picture}
+	}
+	expected := [][]string{
+		{"xfconf-query", "--channel", "xfce4-desktop", "--list"},
+		set("/backdrop/screen0/monitorHDMI-1/workspace0/last-image", "/pictures/a.jpg"),
+		set("/backdrop/screen0/monitoreDP-1/workspace0/last-image", "/pictures/b.jpg"),
+		set("/backdrop/screen0/monitoreDP-1/workspace1/last-image", "/pictures/b.jpg"),
+	}
+	if !reflect.DeepEqual(*commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, *commands)
+	}
+}
@@ -0,0 +1,38 @@
+package de
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches the monitor lines of `xrandr --listactivemonitors`, like
+// " 1: +HDMI-1 2560/597x1440/336+1920+0  HDMI-1"
+var xrandrMonitor = regexp.MustCompile(`^\s*\d+:\s+\+?\*?(\S+)\s+(\d+)/\d+x(\d+)/\d+\+(-?\d+)\+(-?\d+)`)
+
+// xrandrOutputs lists the active monitors in the order Xinerama numbers
+// them, which is the order tools like feh assign pictures in.
+func xrandrOutputs() ([]Output, error) {
+	commandOutput, err := runCommand("xrandr", "--listactivemonitors")
+	if err != nil {
+		return nil, Retryable(fmt.Errorf("xrandr failed: %v %s", err, commandOutput))
+	}
+	var outputs []Output
+	for _, line := range strings.Split(string(commandOutput), "\n") {
+		match := xrandrMonitor.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		output := Output{Name: match[1]}
+		output.Width, _ = strconv.Atoi(match[2])
+		output.Height, _ = strconv.Atoi(match[3])
+		output.X, _ = strconv.Atoi(match[4])
+		output.Y, _ = strconv.Atoi(match[5])
+		outputs = append(outputs, output)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("xrandr reported no active monitors")
+	}
+	return outputs, nil
+}
@@ -0,0 +1,36 @@
+package de
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testXrandrMonitors = `Monitors: 2
+ 0: +*eDP-1 1920/344x1080/194+0+0  eDP-1
+ 1: +HDMI-1 2560/597x1440/336+1920+-360  HDMI-1
+`
+
+func TestXrandrOutputs(t *testing.T) {
+	stubRunner(t, func(command []string) ([]byte, error) { return []byte(testXrandrMonitors), nil })
+	outputs, err := xrandrOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Output{
+		{Name: "eDP-1", Width: 1920, Height: 1080},
+		{Name: "HDMI-1", Width: 2560, Height: 1440, X: 1920, Y: -360},
+	}
+	if !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("Expected %v, got %v", expected, outputs)
+	}
+
+	stubRunner(t, func(command []string) ([]byte, error) { return []byte("Monitors: 0\n"), nil })
+	if outputs, err := xrandrOutputs(); err == nil {
+		t.Errorf("Expected an error without monitors, got %v", outputs)
+	}
+	stubCommands(t, errors.New("exit status 1"))
+	if outputs, err := xrandrOutputs(); err == nil {
+		t.Errorf("Expected an error without xrandr, got %v", outputs)
+	}
+}
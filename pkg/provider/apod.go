@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const apodDateLayout = "2006-01-02"
+
+// APOD days change in US Eastern time, a few hours after UTC ones
+const apodTimeOffset = -5 * time.Hour
+
+// ApodProvider lists NASA's Astronomy Pictures of the Day, either the last
+// days or a fixed range of dates to backfill the archive. Days with a video
+// instead of a picture are skipped.
+type ApodProvider struct {
+	baseURL   string
+	apiKey    string
+	days      int
+	startDate string
+	endDate   string
+	hd        bool
+	interval  time.Duration
+	client    http.Client
+
+	listed map[string]apodPicture
+}
+
+type apodPicture struct {
+	Date      string `json:"date"`
+	Title     string `json:"title"`
+	Copyright string `json:"copyright"`
+	MediaType string `json:"media_type"`
+	URL       string `json:"url"`
+	HDURL     string `json:"hdurl"`
+}
+
+func (ap *ApodProvider) apodURL() string {
+	query := url.Values{}
+	query.Set("api_key", ap.apiKey)
+	if ap.startDate != "" {
+		query.Set("start_date", ap.startDate)
+		if ap.endDate != "" {
+			query.Set("end_date", ap.endDate)
+		}
+	} else {
+		today := time.Now().UTC().Add(apodTimeOffset)
+		query.Set("start_date", today.AddDate(0, 0, 1-ap.days).Format(apodDateLayout))
+	}
+	return fmt.Sprintf("%v/planetary/apod?%v", ap.baseURL, query.Encode())
+}
+
+// apodPageURL is the APOD page of the picture of a date
+func apodPageURL(date string) string {
+	day, err := time.Parse(apodDateLayout, date)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("https://apod.nasa.gov/apod/ap%v.html", day.Format("060102"))
+}
+
+func (ap *ApodProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	var pictures []apodPicture
+	if err := getJSON(ctx, &ap.client, ap.apodURL(), nil, &pictures); err != nil {
+		return nil, err
+	}
+	photos := make([]string, 0)
+	ap.listed = make(map[string]apodPicture)
+	for _, picture := range pictures {
+		if picture.MediaType != "image" {
+			logger.Debugf("Skipping APOD of %v, it's a %v", picture.Date, picture.MediaType)
+			continue
+		}
+		photo := picture.URL
+		if ap.hd && picture.HDURL != "" {
+			photo = picture.HDURL
+		}
+		if photo == "" {
+			continue
+		}
+		ap.listed[photo] = picture
+		photos = append(photos, photo)
+	}
+	return photos, nil
+}
+
+func (ap *ApodProvider) DescribePhoto(photo string) map[string]interface{} {
+	picture, ok := ap.listed[photo]
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"source":    "apod",
+		"date":      picture.Date,
+		"title":     picture.Title,
+		"copyright": strings.TrimSpace(picture.Copyright),
+		"url":       apodPageURL(picture.Date),
+	}
+}
+
+func (ap *ApodProvider) GetName() string {
+	return "apod"
+}
+
+func (ap *ApodProvider) SetStorageLocation(location string) {
+}
+
+func (ap *ApodProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = ap
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, ap.interval)
+}
+
+func GetApodPhotoProvider(config map[string]interface{}) PhotoProvider {
+	ap := &ApodProvider{
+		baseURL:  "https://api.nasa.gov",
+		days:     7,
+		hd:       true,
+		interval: pollInterval(config, 21600),
+		client:   http.Client{Timeout: time.Minute},
+	}
+	ap.apiKey, _ = config["api_key"].(string)
+	if ap.apiKey == "" {
+		ap.apiKey = os.Getenv("NASA_API_KEY")
+	}
+	if ap.apiKey == "" {
+		logger.Infof("No api_key config parameter nor NASA_API_KEY, using the rate limited DEMO_KEY")
+		ap.apiKey = "DEMO_KEY"
+	}
+	if baseURL, ok := config["base_url"].(string); ok {
+		ap.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if days, ok := config["days"].(float64); ok && days > 0 {
+		ap.days = int(days)
+	}
+	for key, date := range map[string]*string{"start_date": &ap.startDate, "end_date": &ap.endDate} {
+		value, ok := config[key].(string)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse(apodDateLayout, value); err != nil {
+			logger.Errorf("%v config parameter %q is not a YYYY-MM-DD date", key, value)
+			return nil
+		}
+		*date = value
+	}
+	if ap.endDate != "" && ap.startDate == "" {
+		logger.Errorf("end_date config parameter needs a start_date")
+		return nil
+	}
+	if hd, ok := config["hd"].(bool); ok {
+		ap.hd = hd
+	}
+
+	var pl PhotoProvider = &PhotoDownloader{backend: ap, client: &ap.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("apod", GetApodPhotoProvider)
+}
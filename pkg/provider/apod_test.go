@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestApodProvider(t *testing.T) {
+	server, requests := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		if request.URL.Path != "/planetary/apod" {
+			return false
+		}
+		fmt.Fprintf(writer, `[
+			{"date": "2026-01-01", "title": "Nebula", "copyright": " Author\n", "media_type": "image", "url": "%[1]v/nebula.jpg", "hdurl": "%[1]v/nebula-hd.jpg"},
+			{"date": "2026-01-02", "title": "Launch", "media_type": "video", "url": "%[1]v/launch"},
+			{"date": "2026-01-03", "title": "Galaxy", "media_type": "image", "url": "%[1]v/galaxy.jpg"}
+		]`, server.URL)
+		return true
+	})
+	ap := GetApodPhotoProvider(map[string]interface{}{
+		"base_url":   server.URL,
+		"api_key":    "key",
+		"start_date": "2026-01-01",
+		"end_date":   "2026-01-03",
+	}).(*PhotoDownloader).backend.(*ApodProvider)
+
+	photos, err := ap.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{server.URL + "/nebula-hd.jpg", server.URL + "/galaxy.jpg"}; !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected photos %v, got %v", expected, photos)
+	}
+	if expected := "/planetary/apod?api_key=key&end_date=2026-01-03&start_date=2026-01-01"; len(*requests) != 1 || (*requests)[0] != expected {
+		t.Errorf("Expected request %v, got %v", expected, *requests)
+	}
+	metadata := ap.DescribePhoto(photos[0])
+	if metadata["copyright"] != "Author" || metadata["url"] != "https://apod.nasa.gov/apod/ap260101.html" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+
+	for _, config := range []map[string]interface{}{
+		{"start_date": "01/01/2026"},
+		{"end_date": "2026-01-03"},
+	} {
+		if provider := GetApodPhotoProvider(config); provider != nil {
+			t.Errorf("Config %v was accepted", config)
+		}
+	}
+}
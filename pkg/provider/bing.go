@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Bing answers at most 8 images per request starting from idx 0 to 7,
+// so 15 days is as far back as it can be asked.
+const (
+	bingMaxDays    = 15
+	bingMaxIndex   = 7
+	bingMaxPerPage = 8
+)
+
+// BingProvider lists the Bing images of the day of a market. Photos
+// downloaded are never removed, so the archive grows day after day.
+type BingProvider struct {
+	baseURL    string
+	market     string
+	resolution string
+	days       int
+	interval   time.Duration
+	client     http.Client
+
+	listed map[string]bingImage
+}
+
+type bingImage struct {
+	StartDate     string `json:"startdate"`
+	URLBase       string `json:"urlbase"`
+	Title         string `json:"title"`
+	Copyright     string `json:"copyright"`
+	CopyrightLink string `json:"copyrightlink"`
+}
+
+func (bp *BingProvider) archiveURL(index, number int) string {
+	query := url.Values{}
+	query.Set("format", "js")
+	query.Set("idx", fmt.Sprint(index))
+	query.Set("n", fmt.Sprint(number))
+	query.Set("mkt", bp.market)
+	return fmt.Sprintf("%v/HPImageArchive.aspx?%v", bp.baseURL, query.Encode())
+}
+
+func (bp *BingProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	photos := make([]string, 0)
+	bp.listed = make(map[string]bingImage)
+	pages := [][2]int{{0, bp.days}}
+	if bp.days > bingMaxPerPage {
+		// The second page overlaps the first, duplicates are skipped
+		pages = [][2]int{{0, bingMaxPerPage}, {bingMaxIndex, bp.days - bingMaxIndex}}
+	}
+	for _, page := range pages {
+		var archive struct {
+			Images []bingImage `json:"images"`
+		}
+		if err := getJSON(ctx, &bp.client, bp.archiveURL(page[0], page[1]), nil, &archive); err != nil {
+			return nil, err
+		}
+		for _, image := range archive.Images {
+			if image.URLBase == "" {
+				continue
+			}
+			photo := fmt.Sprintf("%v%v_%v.jpg", bp.baseURL, image.URLBase, bp.resolution)
+			if _, ok := bp.listed[photo]; ok {
+				continue
+			}
+			bp.listed[photo] = image
+			photos = append(photos, photo)
+		}
+	}
+	return photos, nil
+}
+
+func (bp *BingProvider) DescribePhoto(photo string) map[string]interface{} {
+	image, ok := bp.listed[photo]
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"source":    "bing",
+		"date":      image.StartDate,
+		"title":     image.Title,
+		"copyright": image.Copyright,
+		"url":       image.CopyrightLink,
+	}
+}
+
+func (bp *BingProvider) GetName() string {
+	return fmt.Sprintf("bing-%v", bp.market)
+}
+
+func (bp *BingProvider) SetStorageLocation(location string) {
+}
+
+func (bp *BingProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = bp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, bp.interval)
+}
+
+func GetBingPhotoProvider(config map[string]interface{}) PhotoProvider {
+	bp := &BingProvider{
+		baseURL:    "https://www.bing.com",
+		market:     "en-US",
+		resolution: "UHD",
+		days:       1,
+		interval:   pollInterval(config, 21600),
+		client:     http.Client{Timeout: time.Minute},
+	}
+	if baseURL, ok := config["base_url"].(string); ok {
+		bp.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if market, ok := config["market"].(string); ok && market != "" {
+		bp.market = market
+	}
+	if resolution, ok := config["resolution"].(string); ok && resolution != "" {
+		bp.resolution = resolution
+	}
+	if days, ok := config["days"].(float64); ok && days > 0 {
+		bp.days = int(days)
+	}
+	if bp.days > bingMaxDays {
+		logger.Warningf("Bing only keeps %v days of images, not %v", bingMaxDays, bp.days)
+		bp.days = bingMaxDays
+	}
+
+	var pl PhotoProvider = &PhotoDownloader{backend: bp, client: &bp.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("bing", GetBingPhotoProvider)
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+func TestBingProvider(t *testing.T) {
+	util.RegisterSupportedFormat("jpg")
+	server, requests := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		if request.URL.Path != "/HPImageArchive.aspx" {
+			return false
+		}
+		// Every page answers the image of day 7, shared by both pages
+		index := request.URL.Query().Get("idx")
+		fmt.Fprintf(writer, `{"images": [
+			{"startdate": "2026010%[1]v", "urlbase": "/th?id=OHR.Day%[1]v", "title": "Day %[1]v", "copyright": "Author"},
+			{"startdate": "20260107", "urlbase": "/th?id=OHR.Day7", "title": "Day 7"},
+			{"startdate": "20260100", "urlbase": ""}
+		]}`, index)
+		return true
+	})
+	bp := GetBingPhotoProvider(map[string]interface{}{
+		"base_url":   server.URL,
+		"market":     "es-ES",
+		"resolution": "1920x1080",
+		"days":       10.0,
+	}).(*PhotoDownloader).backend.(*BingProvider)
+
+	photos, err := bp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		server.URL + "/th?id=OHR.Day0_1920x1080.jpg",
+		server.URL + "/th?id=OHR.Day7_1920x1080.jpg",
+	}
+	if !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected photos %v, got %v", expected, photos)
+	}
+	pages := []string{
+		"/HPImageArchive.aspx?format=js&idx=0&mkt=es-ES&n=8",
+		"/HPImageArchive.aspx?format=js&idx=7&mkt=es-ES&n=3",
+	}
+	if !reflect.DeepEqual(*requests, pages) {
+		t.Errorf("Expected requests %v, got %v", pages, *requests)
+	}
+	if metadata := bp.DescribePhoto(photos[0]); metadata["title"] != "Day 0" || metadata["copyright"] != "Author" || metadata["date"] != "20260100" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+	if bp.GetName() != "bing-es-ES" {
+		t.Errorf("Unexpected name %v", bp.GetName())
+	}
+
+	if days := GetBingPhotoProvider(map[string]interface{}{"days": 30.0}).(*PhotoDownloader).backend.(*BingProvider).days; days != bingMaxDays {
+		t.Errorf("Expected days to be capped at %v, got %v", bingMaxDays, days)
+	}
+}
@@ -2,8 +2,11 @@ package provider
 
 import (
 	"encoding/json"
-	"os"
+	"io/ioutil"
 	"path/filepath"
+	"strings"
+
+	"github.com/txomon/sawyer/pkg/util"
 )
 
 type MapMemory struct {
@@ -28,23 +31,57 @@ func (mp *MapMemory) setMemory(key, value string) {
 		logger.Tracef("Key %v existed in memory map with %v. Setting to %v", key, val, value)
 	}
 	mp.memory[key] = value
-	file, err := os.Create(mp.memoryFile)
-	if err != nil {
-		logger.Infof("Failed to create file %v for memory")
-		return
+	mp.save()
+}
+
+// forgetOthers forgets every key starting with prefix except keep,
+// returning the values no key refers to anymore.
+func (mp *MapMemory) forgetOthers(prefix, keep string) []string {
+	forgotten := make(map[string]bool)
+	for key, value := range mp.memory {
+		if key != keep && strings.HasPrefix(key, prefix) {
+			delete(mp.memory, key)
+			forgotten[value] = true
+		}
+	}
+	if len(forgotten) == 0 {
+		return nil
 	}
+	for _, value := range mp.memory {
+		delete(forgotten, value)
+	}
+	mp.save()
+	var unreferenced []string
+	for value := range forgotten {
+		unreferenced = append(unreferenced, value)
+	}
+	return unreferenced
+}
+
+func (mp *MapMemory) save() {
 	bytes, err := json.Marshal(mp.memory)
 	if err != nil {
 		logger.Infof("Failed to marshal %v", mp.memory)
 		return
 	}
-	file.Write(bytes)
+	if err := util.WriteFileAtomic(mp.memoryFile, bytes); err != nil {
+		logger.Infof("Failed to write file %v for memory. %v", mp.memoryFile, err)
+	}
 }
 
+// NewMemory loads the memory kept in cacheDir, so what was cached before a
+// restart isn't fetched again.
 func NewMemory(cacheDir string) MapMemory {
 	memoryFile := filepath.Join(cacheDir, "memory-map.json")
+	memory := make(map[string]string)
+	if content, err := ioutil.ReadFile(memoryFile); err == nil {
+		if err := json.Unmarshal(content, &memory); err != nil {
+			logger.Infof("Failed to load memory from %v, starting empty. %v", memoryFile, err)
+			memory = make(map[string]string)
+		}
+	}
 	return MapMemory{
 		memoryFile: memoryFile,
-		memory:     make(map[string]string),
+		memory:     memory,
 	}
 }
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryKeptAcrossRestarts(t *testing.T) {
+	cacheDir := t.TempDir()
+	memory := NewMemory(cacheDir)
+	memory.setMemory("https://example.com/a.jpg", filepath.Join(cacheDir, "a.jpg"))
+
+	restarted := NewMemory(cacheDir)
+	if cached := restarted.getMemory("https://example.com/a.jpg"); cached != filepath.Join(cacheDir, "a.jpg") {
+		t.Errorf("Memory lost on restart, got %q", cached)
+	}
+	// Only the memory file is left, written through a temporary one
+	if files, _ := ioutil.ReadDir(cacheDir); len(files) != 1 || files[0].Name() != "memory-map.json" {
+		t.Errorf("Unexpected files in the cache dir %v", files)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(cacheDir, "memory-map.json"), []byte("{broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	broken := NewMemory(cacheDir)
+	if cached := broken.getMemory("https://example.com/a.jpg"); cached != "" {
+		t.Errorf("Broken memory was loaded, got %q", cached)
+	}
+}
+
+func TestMemoryForgetOthers(t *testing.T) {
+	memory := NewMemory(t.TempDir())
+	memory.setMemory("s3/a.png#one", "/cache/one.png")
+	memory.setMemory("s3/a.png#two", "/cache/shared.png")
+	memory.setMemory("s3/b.png#one", "/cache/shared.png")
+	memory.setMemory("s3/a.png#three", "/cache/three.png")
+
+	unreferenced := memory.forgetOthers("s3/a.png#", "s3/a.png#three")
+	if !reflect.DeepEqual(unreferenced, []string{"/cache/one.png"}) {
+		t.Errorf("Expected only the unshared copy to go, got %v", unreferenced)
+	}
+	for key, expected := range map[string]string{
+		"s3/a.png#one":   "",
+		"s3/a.png#two":   "",
+		"s3/a.png#three": "/cache/three.png",
+		"s3/b.png#one":   "/cache/shared.png",
+	} {
+		if cached := memory.getMemory(key); cached != expected {
+			t.Errorf("Expected %v to be %q, got %q", key, expected, cached)
+		}
+	}
+	if restarted := NewMemory(filepath.Dir(memory.memoryFile)); restarted.getMemory("s3/a.png#one") != "" {
+		t.Errorf("Forgotten keys back after a restart")
+	}
+}
@@ -1,30 +1,60 @@
 package provider
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"io/ioutil"
 
 	"github.com/txomon/sawyer/pkg/util"
 )
 
+// PhotoDescriber is implemented by backends knowing more of the photos
+// they list than their URL, like their author. It's kept as the sidecar
+// metadata of the downloaded file.
+type PhotoDescriber interface {
+	DescribePhoto(photo string) map[string]interface{}
+}
+
+// DownloadTracker is implemented by backends that have to report every
+// photo downloaded, as some APIs ask for.
+type DownloadTracker interface {
+	TrackDownload(ctx context.Context, photo string) error
+}
+
+// RequestDecorator is implemented by backends whose photos can't be just
+// fetched, like the ones needing the request to be signed.
+type RequestDecorator interface {
+	DecorateRequest(request *http.Request) error
+}
+
+// PhotoVersioner is implemented by backends telling when a photo changed
+// under the same URL, like with an ETag. A photo is downloaded again when
+// its version changes.
+type PhotoVersioner interface {
+	PhotoVersion(photo string) string
+}
+
 type PhotoDownloader struct {
 	backend        PhotoProvider
 	cacheDirectory string
 	memory         MapMemory
+	// client is the one of the backend, with its timeout
+	client *http.Client
 }
 
-func (pd *PhotoDownloader) Run(photoProvider *PhotoProvider) {
+func (pd *PhotoDownloader) Run(ctx context.Context, photoProvider *PhotoProvider) {
 	var pp PhotoProvider = pd
 	if photoProvider == nil {
 		photoProvider = &pp
 	}
-	pd.backend.Run(photoProvider)
+	pd.backend.Run(ctx, photoProvider)
 }
 func (pd *PhotoDownloader) SetStorageLocation(cacheDirectory string) {
 	pd.cacheDirectory = cacheDirectory
@@ -38,15 +68,54 @@ func (pd *PhotoDownloader) GetName() string {
 	return pd.backend.GetName()
 }
 
-func (pd *PhotoDownloader) GetPhotos() ([]string, error) {
+// downloadGrace is how long the download in flight gets to finish once
+// the provider is stopped, well within the shutdown timeout of sawyer.
+var downloadGrace = 10 * time.Second
+
+// graceContext is done downloadGrace after ctx is, or once cancelled
+func graceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	graced, cancel := context.WithCancel(context.Background())
+	grace := downloadGrace
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-graced.Done():
+			return
+		}
+		select {
+		case <-time.After(grace):
+			cancel()
+		case <-graced.Done():
+		}
+	}()
+	return graced, cancel
+}
+
+// GetPhotos downloads the photos the backend lists. Once ctx is done no new
+// downloads are started, and the one in flight gets downloadGrace to
+// finish. Photos are written atomically, so an abandoned download never
+// leaves a partial one cached.
+func (pd *PhotoDownloader) GetPhotos(ctx context.Context) ([]string, error) {
 	var photos []string
-	backendPhotos, err := pd.backend.GetPhotos()
+	backendPhotos, err := pd.backend.GetPhotos(ctx)
 	if err != nil {
 		logger.Errorf("PhotoDownloader encountered an error from backend %v getPhotos", pd.backend.GetName())
 		return nil, err
 	}
+	downloadCtx, cancel := graceContext(ctx)
+	defer cancel()
 	for _, photo := range backendPhotos {
-		if cachedFile := pd.memory.getMemory(photo); cachedFile != "" {
+		if ctx.Err() != nil {
+			logger.Debugf("Stopping downloads from %v", pd.backend.GetName())
+			break
+		}
+		memoryKey := photo
+		if versioner, ok := pd.backend.(PhotoVersioner); ok {
+			if version := versioner.PhotoVersion(photo); version != "" {
+				memoryKey = fmt.Sprintf("%v#%v", photo, version)
+			}
+		}
+		if cachedFile := pd.memory.getMemory(memoryKey); cachedFile != "" {
 			if _, err := os.Stat(cachedFile); err == nil {
 				photos = append(photos, cachedFile)
 				logger.Tracef("Cached file, nothing needs to be done")
@@ -55,15 +124,9 @@ func (pd *PhotoDownloader) GetPhotos() ([]string, error) {
 				logger.Tracef("Cached file deleted, continuing as if not cached")
 			}
 		}
-		res, err := http.Get(photo)
+		photoContent, err := pd.download(downloadCtx, photo)
 		if err != nil {
 			logger.Warningf("Failed to GET photo %v. %v", photo, err)
-		}
-		logger.Tracef("Got photo %v %v", photo, res.Status)
-		defer res.Body.Close()
-		photoContent, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			logger.Infof("Failed to read all the body from %v. %v", photo, err)
 			continue
 		}
 		format := util.IsBackground(photo, photoContent)
@@ -84,28 +147,84 @@ func (pd *PhotoDownloader) GetPhotos() ([]string, error) {
 				continue
 			}
 		} else {
-			photoFile, err := os.Create(photoPath)
-			if err != nil {
-				logger.Warningf("Creating file %v failed. %v", photoPath, err)
-				continue
-			}
-
-			size, err := photoFile.Write(photoContent)
-			if err != nil {
+			if err := util.WriteFileAtomic(photoPath, photoContent); err != nil {
 				logger.Warningf("Writing in file %v failed. %v", photoPath, err)
 				continue
 			}
-			logger.Debugf("Written %v bytes to %v", size, photoPath)
+			logger.Debugf("Written %v bytes to %v", len(photoContent), photoPath)
+			pd.trackDownload(downloadCtx, photo)
+		}
+		pd.describePhoto(photo, photoPath)
 
-			err = photoFile.Close()
-			if err != nil {
-				logger.Warningf("Closing the file failed. %v", err)
-				continue
+		pd.memory.setMemory(memoryKey, photoPath)
+		if memoryKey != photo {
+			pd.pruneVersions(photo, memoryKey)
+		}
+		photos = append(photos, photoPath)
+	}
+	return photos, nil
+}
+
+// pruneVersions removes the copies of photo superseded by the version in
+// memoryKey, unless another photo has the same content.
+func (pd *PhotoDownloader) pruneVersions(photo, memoryKey string) {
+	for _, superseded := range pd.memory.forgetOthers(photo+"#", memoryKey) {
+		logger.Debugf("Removing %v, superseded by a newer version of %v", superseded, photo)
+		for _, path := range []string{superseded, superseded + util.MetadataExtension} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				logger.Infof("Failed to remove %v. %v", path, err)
 			}
 		}
+	}
+}
 
-		pd.memory.setMemory(photo, photoPath)
-		photos = append(photos, photoPath)
+func (pd *PhotoDownloader) download(ctx context.Context, photo string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", photo, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", userAgent)
+	if decorator, ok := pd.backend.(RequestDecorator); ok {
+		if err := decorator.DecorateRequest(request); err != nil {
+			return nil, err
+		}
+	}
+	client := pd.client
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	logger.Tracef("Got photo %v %v", photo, response.Status)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, StatusError{URL: photo, StatusCode: response.StatusCode, Status: response.Status}
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+func (pd *PhotoDownloader) trackDownload(ctx context.Context, photo string) {
+	tracker, ok := pd.backend.(DownloadTracker)
+	if !ok {
+		return
+	}
+	if err := tracker.TrackDownload(ctx, photo); err != nil {
+		logger.Infof("Failed to track the download of %v. %v", photo, err)
+	}
+}
+
+func (pd *PhotoDownloader) describePhoto(photo, photoPath string) {
+	describer, ok := pd.backend.(PhotoDescriber)
+	if !ok {
+		return
+	}
+	metadata := describer.DescribePhoto(photo)
+	if metadata == nil {
+		return
+	}
+	if err := util.WriteMetadata(photoPath, metadata); err != nil {
+		logger.Warningf("Failed to write metadata of %v. %v", photoPath, err)
 	}
-	return photos, err
 }
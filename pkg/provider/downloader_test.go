@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+// listProvider lists the same photos every time
+type listProvider struct {
+	photos []string
+}
+
+func (lp *listProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	return lp.photos, nil
+}
+
+func (lp *listProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {}
+
+func (lp *listProvider) GetName() string {
+	return "list"
+}
+
+func (lp *listProvider) SetStorageLocation(location string) {}
+
+func TestPhotoDownloaderFinishesInFlight(t *testing.T) {
+	util.RegisterSupportedFormat("jpg")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, requests := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		// Shutdown arrives while the first photo is downloading
+		cancel()
+		time.Sleep(50 * time.Millisecond)
+		writer.Write([]byte("photo"))
+		return true
+	})
+
+	pd := &PhotoDownloader{backend: &listProvider{photos: []string{server.URL + "/first.jpg", server.URL + "/second.jpg"}}}
+	pd.SetStorageLocation(t.TempDir())
+	photos, err := pd.GetPhotos(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 1 {
+		t.Fatalf("Expected the photo in flight to be downloaded, got %v", photos)
+	}
+	if content, err := ioutil.ReadFile(photos[0]); err != nil || string(content) != "photo" {
+		t.Errorf("Photo not cached completely, %q %v", content, err)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("Download started after shutdown, %v", *requests)
+	}
+}
+
+func TestPhotoDownloaderAbandonsStalledDownload(t *testing.T) {
+	original := downloadGrace
+	t.Cleanup(func() { downloadGrace = original })
+	downloadGrace = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	server, _ := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		// Half the photo arrives, then shutdown while the rest stalls
+		writer.Write(testPicture(request.URL.Path)[:20])
+		writer.(http.Flusher).Flush()
+		cancel()
+		select {
+		case <-request.Context().Done():
+		case <-release:
+		}
+		return true
+	})
+
+	cacheDir := t.TempDir()
+	pd := &PhotoDownloader{
+		backend: &listProvider{photos: []string{server.URL + "/stalled.png"}},
+		client:  &http.Client{Timeout: time.Minute},
+	}
+	pd.SetStorageLocation(cacheDir)
+	start := time.Now()
+	photos, err := pd.GetPhotos(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 0 {
+		t.Errorf("Expected no photos, got %v", photos)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Download kept going %v after the grace period", elapsed)
+	}
+	if files, _ := ioutil.ReadDir(cacheDir); len(files) != 0 {
+		t.Errorf("Partial download left in the cache, %v", files)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if !sleep(context.Background(), time.Millisecond) {
+		t.Errorf("Sleep interrupted without being cancelled")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if sleep(ctx, time.Minute) || time.Since(start) > 5*time.Second {
+		t.Errorf("Sleep not interrupted when cancelled")
+	}
+}
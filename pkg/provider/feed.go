@@ -0,0 +1,277 @@
+package provider
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var imgSource = regexp.MustCompile(`(?i)<img[^>]+src\s*=\s*["']([^"']+)["']`)
+
+// FeedProvider lists the pictures of an RSS 2.0, Atom or Media RSS feed.
+// Each entry gives its Media RSS content or image enclosure, or when it has
+// none, the images in its HTML. The feed is only downloaded again when it
+// changed, according to its ETag or Last-Modified.
+type FeedProvider struct {
+	feedURL  string
+	limit    int
+	interval time.Duration
+	client   http.Client
+
+	etag         string
+	lastModified string
+	photos       []string
+	listed       map[string]feedEntry
+}
+
+type feed struct {
+	Items   []feedEntry `xml:"channel>item"`
+	Entries []feedEntry `xml:"entry"`
+}
+
+type feedEntry struct {
+	Title        string      `xml:"title"`
+	Links        []feedLink  `xml:"link"`
+	Enclosures   []feedMedia `xml:"enclosure"`
+	MediaContent []feedMedia `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups  []struct {
+		Content []feedMedia `xml:"http://search.yahoo.com/mrss/ content"`
+	} `xml:"http://search.yahoo.com/mrss/ group"`
+	MediaCredit string     `xml:"http://search.yahoo.com/mrss/ credit"`
+	Encoded     feedHTML   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Creator     string     `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Description feedHTML   `xml:"description"`
+	Content     feedHTML   `xml:"content"`
+	Summary     feedHTML   `xml:"summary"`
+	Author      feedAuthor `xml:"author"`
+}
+
+// feedLink is either an RSS link, with the URL as text, or an Atom one
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type feedMedia struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Medium string `xml:"medium,attr"`
+	Width  int    `xml:"width,attr"`
+}
+
+// feedHTML keeps the markup of escaped, CDATA and inline XHTML contents
+type feedHTML struct {
+	Inner string `xml:",innerxml"`
+}
+
+type feedAuthor struct {
+	Name string `xml:"name"`
+	Text string `xml:",chardata"`
+}
+
+func (fm feedMedia) isImage() bool {
+	if fm.Medium != "" {
+		return fm.Medium == "image"
+	}
+	if fm.Type != "" {
+		return strings.HasPrefix(fm.Type, "image/")
+	}
+	return isImageURL(fm.URL)
+}
+
+func (fe feedEntry) link() string {
+	for _, link := range fe.Links {
+		if link.Href == "" && strings.TrimSpace(link.Text) != "" {
+			return strings.TrimSpace(link.Text)
+		}
+		if link.Href != "" && (link.Rel == "" || link.Rel == "alternate") {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func (fe feedEntry) author() string {
+	for _, author := range []string{fe.MediaCredit, fe.Creator, fe.Author.Name, fe.Author.Text} {
+		if author = strings.TrimSpace(author); author != "" {
+			return author
+		}
+	}
+	return ""
+}
+
+// pictures are the URLs of the pictures of the entry, the widest Media RSS
+// one first
+func (fe feedEntry) pictures() []string {
+	var media []feedMedia
+	media = append(media, fe.MediaContent...)
+	for _, group := range fe.MediaGroups {
+		media = append(media, group.Content...)
+	}
+	var widest *feedMedia
+	for index := range media {
+		if media[index].URL != "" && media[index].isImage() && (widest == nil || media[index].Width > widest.Width) {
+			widest = &media[index]
+		}
+	}
+	if widest != nil {
+		return []string{widest.URL}
+	}
+
+	for _, enclosure := range fe.Enclosures {
+		if enclosure.URL != "" && enclosure.isImage() {
+			return []string{enclosure.URL}
+		}
+	}
+	for _, link := range fe.Links {
+		if link.Rel == "enclosure" && link.Href != "" && (feedMedia{URL: link.Href, Type: link.Type}).isImage() {
+			return []string{link.Href}
+		}
+	}
+
+	var pictures []string
+	for _, content := range []feedHTML{fe.Encoded, fe.Content, fe.Description, fe.Summary} {
+		markup := html.UnescapeString(content.Inner)
+		for _, match := range imgSource.FindAllStringSubmatch(markup, -1) {
+			pictures = append(pictures, html.UnescapeString(match[1]))
+		}
+		if len(pictures) != 0 {
+			break
+		}
+	}
+	return pictures
+}
+
+// fetch downloads the feed, returning nil when it didn't change
+func (fp *FeedProvider) fetch(ctx context.Context) (*feed, error) {
+	headers := map[string]string{}
+	if fp.etag != "" {
+		headers["If-None-Match"] = fp.etag
+	}
+	if fp.lastModified != "" {
+		headers["If-Modified-Since"] = fp.lastModified
+	}
+	response, err := get(ctx, &fp.client, fp.feedURL, headers)
+	var statusError StatusError
+	if errors.As(err, &statusError) && statusError.StatusCode == http.StatusNotModified {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var parsed feed
+	decoder := xml.NewDecoder(response.Body)
+	decoder.Strict = false
+	// Feeds in other charsets are read as they are, the URLs in them are
+	// ASCII anyway
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("parsing feed %v: %v", fp.feedURL, err)
+	}
+	fp.etag = response.Header.Get("ETag")
+	fp.lastModified = response.Header.Get("Last-Modified")
+	return &parsed, nil
+}
+
+func (fp *FeedProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	parsed, err := fp.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if parsed == nil {
+		logger.Debugf("Feed %v didn't change", fp.feedURL)
+		return fp.photos, nil
+	}
+
+	base, err := url.Parse(fp.feedURL)
+	if err != nil {
+		return nil, err
+	}
+	photos := make([]string, 0)
+	listed := make(map[string]feedEntry)
+	for _, entry := range append(parsed.Items, parsed.Entries...) {
+		for _, picture := range entry.pictures() {
+			reference, err := url.Parse(strings.TrimSpace(picture))
+			if err != nil {
+				logger.Debugf("Skipping invalid picture URL %q. %v", picture, err)
+				continue
+			}
+			photo := base.ResolveReference(reference).String()
+			if _, ok := listed[photo]; ok {
+				continue
+			}
+			listed[photo] = entry
+			photos = append(photos, photo)
+		}
+		if fp.limit > 0 && len(photos) >= fp.limit {
+			photos = photos[:fp.limit]
+			break
+		}
+	}
+	fp.photos, fp.listed = photos, listed
+	return photos, nil
+}
+
+func (fp *FeedProvider) DescribePhoto(photo string) map[string]interface{} {
+	entry, ok := fp.listed[photo]
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"source": "feed",
+		"title":  strings.TrimSpace(entry.Title),
+		"author": entry.author(),
+		"url":    entry.link(),
+	}
+}
+
+func (fp *FeedProvider) GetName() string {
+	return fmt.Sprintf("feed-%v", nameFromURL(fp.feedURL))
+}
+
+func (fp *FeedProvider) SetStorageLocation(location string) {
+}
+
+func (fp *FeedProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = fp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, fp.interval)
+}
+
+func GetFeedPhotoProvider(config map[string]interface{}) PhotoProvider {
+	feedURL, ok := config["url"].(string)
+	if !ok || feedURL == "" {
+		logger.Errorf("url config parameter is not a string as expected")
+		return nil
+	}
+	fp := &FeedProvider{
+		feedURL:  feedURL,
+		interval: pollInterval(config, 3600),
+		client:   http.Client{Timeout: time.Minute},
+	}
+	if limit, ok := config["limit"].(float64); ok && limit > 0 {
+		fp.limit = int(limit)
+	}
+
+	var pl PhotoProvider = &PhotoDownloader{backend: fp, client: &fp.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("feed", GetFeedPhotoProvider)
+}
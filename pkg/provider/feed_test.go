@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+  <item>
+    <title>Media</title>
+    <link>https://example.com/media</link>
+    <dc:creator>Someone</dc:creator>
+    <media:content url="https://example.com/small.jpg" medium="image" width="640"/>
+    <media:content url="https://example.com/large.jpg" medium="image" width="1920"/>
+  </item>
+  <item>
+    <title>Enclosure</title>
+    <enclosure url="/enclosure.png" type="image/png"/>
+    <enclosure url="/podcast.mp3" type="audio/mpeg"/>
+  </item>
+  <item>
+    <title>HTML</title>
+    <description>&lt;p&gt;&lt;img src="https://example.com/inline.jpg"&gt;&lt;/p&gt;</description>
+  </item>
+  <item>
+    <title>Nothing</title>
+    <description>No pictures here</description>
+  </item>
+</channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <title>Atom</title>
+    <link rel="alternate" href="https://example.com/atom"/>
+    <link rel="enclosure" type="image/jpeg" href="https://example.com/atom.jpg"/>
+    <author><name>Author</name></author>
+  </entry>
+</feed>`
+
+// feedServer serves content with an ETag, answering 304 when it's sent back
+func feedServer(t *testing.T, content string) (*httptest.Server, *[]string) {
+	return testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		if request.Header.Get("If-None-Match") == `"v1"` {
+			writer.WriteHeader(http.StatusNotModified)
+			return true
+		}
+		writer.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(writer, content)
+		return true
+	})
+}
+
+func TestFeedProviderRSS(t *testing.T) {
+	server, requests := feedServer(t, testRSS)
+	fp := &FeedProvider{feedURL: server.URL + "/feed.xml"}
+
+	photos, err := fp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"https://example.com/large.jpg",
+		server.URL + "/enclosure.png",
+		"https://example.com/inline.jpg",
+	}
+	if !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected %v, got %v", expected, photos)
+	}
+	metadata := fp.DescribePhoto("https://example.com/large.jpg")
+	if metadata["author"] != "Someone" || metadata["title"] != "Media" || metadata["url"] != "https://example.com/media" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+
+	// The second time the feed didn't change, the pictures are the same
+	photos, err = fp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected %v after 304, got %v", expected, photos)
+	}
+	if len(*requests) != 2 || fp.etag != `"v1"` {
+		t.Errorf("Expected 2 requests with ETag, got %v with %q", *requests, fp.etag)
+	}
+}
+
+func TestFeedProviderAtom(t *testing.T) {
+	server, _ := feedServer(t, testAtom)
+	fp := &FeedProvider{feedURL: server.URL, limit: 1}
+
+	photos, err := fp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(photos, []string{"https://example.com/atom.jpg"}) {
+		t.Errorf("Unexpected photos %v", photos)
+	}
+	metadata := fp.DescribePhoto(photos[0])
+	if metadata["author"] != "Author" || metadata["url"] != "https://example.com/atom" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+}
+
+func TestFeedProviderNameLeavesQueryOut(t *testing.T) {
+	fp := &FeedProvider{feedURL: "https://example.com/feed.xml?token=secret"}
+	if name := fp.GetName(); strings.Contains(name, "secret") || name != "feed-examplecomfeedxml" {
+		t.Errorf("Unexpected name %v", name)
+	}
+}
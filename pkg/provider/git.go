@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+// Directory of the storage location the repository is checked out in. Being
+// hidden, its files aren't taken as photos but through the linker.
+const gitCheckout = ".repo"
+
+// GitProvider exposes the pictures of a git repository, or of a directory
+// in it, the way LocalPhotoProvider does with local ones. The repository is
+// shallow cloned into the storage location and updated on each poll, and
+// pictures deleted upstream are removed from the cache.
+type GitProvider struct {
+	repository   string
+	branch       string
+	subdirectory string
+	checkout     string
+	interval     time.Duration
+
+	blobs map[string]string
+}
+
+// git runs a git command, in the checkout once it's there
+func (gp *GitProvider) git(ctx context.Context, args ...string) (string, error) {
+	subcommand := args[0]
+	if _, err := os.Stat(gp.checkout); err == nil {
+		args = append([]string{"-C", gp.checkout}, args...)
+	}
+	command := exec.CommandContext(ctx, "git", args...)
+	// Never wait for credentials to be typed in
+	command.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	command.Stdout, command.Stderr = &stdout, &stderr
+	if err := command.Run(); err != nil {
+		return "", fmt.Errorf("git %v: %v. %v", subcommand, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// update clones the repository, or brings the checkout to the latest
+// commit of the branch
+func (gp *GitProvider) update(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(gp.checkout, ".git")); err != nil {
+		if err := os.RemoveAll(gp.checkout); err != nil {
+			return err
+		}
+		args := []string{"clone", "--quiet", "--depth", "1", "--single-branch"}
+		if gp.branch != "" {
+			args = append(args, "--branch", gp.branch)
+		}
+		_, err := gp.git(ctx, append(args, "--", gp.repository, gp.checkout)...)
+		return err
+	}
+	branch := "HEAD"
+	if gp.branch != "" {
+		branch = "refs/heads/" + gp.branch
+	}
+	if _, err := gp.git(ctx, "fetch", "--quiet", "--depth", "1", "origin", "--", branch); err != nil {
+		return err
+	}
+	_, err := gp.git(ctx, "reset", "--quiet", "--hard", "FETCH_HEAD")
+	return err
+}
+
+// listBlobs keeps the object of each file checked out, which changes along
+// its content
+func (gp *GitProvider) listBlobs(ctx context.Context) error {
+	files, err := gp.git(ctx, "ls-files", "--stage", "-z")
+	if err != nil {
+		return err
+	}
+	// Photos are listed with absolute paths
+	checkout, err := filepath.Abs(gp.checkout)
+	if err != nil {
+		return err
+	}
+	gp.blobs = make(map[string]string)
+	for _, file := range strings.Split(files, "\x00") {
+		// <mode> <object> <stage>\t<path>
+		fields := strings.SplitN(file, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		if stage := strings.Fields(fields[0]); len(stage) == 3 {
+			gp.blobs[filepath.Join(checkout, filepath.FromSlash(fields[1]))] = stage[1]
+		}
+	}
+	return nil
+}
+
+func (gp *GitProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	if err := gp.update(ctx); err != nil {
+		if _, statErr := os.Stat(filepath.Join(gp.checkout, ".git")); statErr != nil {
+			return nil, err
+		}
+		logger.Infof("Failed to update %v, using the pictures checked out. %v", gp.repository, err)
+	}
+	if err := gp.listBlobs(ctx); err != nil {
+		logger.Infof("Failed to list the files of %v. %v", gp.repository, err)
+	}
+	return util.GetPhotosForPath(filepath.Join(gp.checkout, gp.subdirectory)), nil
+}
+
+func (gp *GitProvider) PhotoVersion(photo string) string {
+	return gp.blobs[photo]
+}
+
+func (gp *GitProvider) GetName() string {
+	name := nameFromURL(gp.repository)
+	if gp.branch != "" {
+		name += "-" + nameFromURL(gp.branch)
+	}
+	if gp.subdirectory != "" {
+		name += "-" + nameFromURL(gp.subdirectory)
+	}
+	return fmt.Sprintf("git-%v", name)
+}
+
+func (gp *GitProvider) SetStorageLocation(location string) {
+	gp.checkout = filepath.Join(location, gitCheckout)
+}
+
+func (gp *GitProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = gp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, gp.interval)
+}
+
+func GetGitPhotoProvider(config map[string]interface{}) PhotoProvider {
+	repository, ok := config["url"].(string)
+	if !ok || repository == "" {
+		logger.Errorf("url config parameter is not a string as expected")
+		return nil
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		logger.Errorf("git provider needs git to be installed. %v", err)
+		return nil
+	}
+	gp := &GitProvider{
+		repository: repository,
+		interval:   pollInterval(config, 3600),
+	}
+	gp.branch, _ = config["branch"].(string)
+	if gp.branch != "" {
+		// Being an argument of git, it could be taken as an option otherwise
+		if output, err := exec.Command("git", "check-ref-format", "--branch", gp.branch).CombinedOutput(); err != nil {
+			logger.Errorf("branch config parameter %q is not a valid branch name. %s", gp.branch, bytes.TrimSpace(output))
+			return nil
+		}
+	}
+	if subdirectory, ok := config["subdirectory"].(string); ok {
+		gp.subdirectory = filepath.Clean("/" + subdirectory)[1:]
+	}
+
+	var pl PhotoProvider = &PhotoLinker{backend: gp, prune: true}
+	return pl
+}
+
+func init() {
+	RegisterProvider("git", GetGitPhotoProvider)
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+// gitRepository is a bare repository with a working copy to push to it
+type gitRepository struct {
+	t    *testing.T
+	bare string
+	work string
+}
+
+func newGitRepository(t *testing.T) *gitRepository {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	directory := t.TempDir()
+	repository := &gitRepository{t: t, bare: filepath.Join(directory, "remote.git"), work: filepath.Join(directory, "work")}
+	repository.run(directory, "init", "--quiet", "--bare", repository.bare)
+	repository.run(directory, "init", "--quiet", repository.work)
+	repository.run(repository.work, "checkout", "--quiet", "-b", "main")
+	return repository
+}
+
+func (gr *gitRepository) run(directory string, args ...string) {
+	command := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	command.Dir = directory
+	if output, err := command.CombinedOutput(); err != nil {
+		gr.t.Fatalf("git %v: %v. %s", args, err, output)
+	}
+}
+
+// writePicture adds a picture of a single color to the working copy
+func (gr *gitRepository) writePicture(name string, shade uint8) {
+	path := filepath.Join(gr.work, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		gr.t.Fatal(err)
+	}
+	picture := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			picture.Set(x, y, color.RGBA{R: shade, A: 255})
+		}
+	}
+	if err := util.SaveImageFormat(picture, path, "png"); err != nil {
+		gr.t.Fatal(err)
+	}
+}
+
+func (gr *gitRepository) push(message string) {
+	gr.run(gr.work, "add", "--all")
+	gr.run(gr.work, "commit", "--quiet", "-m", message)
+	gr.run(gr.work, "push", "--quiet", gr.bare, "main")
+}
+
+func TestGitProvider(t *testing.T) {
+	util.RegisterSupportedFormat("png")
+	repository := newGitRepository(t)
+	repository.writePicture("walls/one.png", 10)
+	repository.writePicture("walls/nested/two.png", 20)
+	repository.writePicture("other.png", 30)
+	repository.push("Add pictures")
+
+	provider := GetGitPhotoProvider(map[string]interface{}{
+		"url":          repository.bare,
+		"branch":       "main",
+		"subdirectory": "walls",
+	})
+	if provider == nil {
+		t.Fatal("Failed to create git provider")
+	}
+	storage := t.TempDir()
+	provider.SetStorageLocation(storage)
+
+	photos, err := provider.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 2 {
+		t.Fatalf("Expected the 2 pictures in walls, got %v", photos)
+	}
+	if _, err := os.Stat(filepath.Join(storage, gitCheckout, "walls", "one.png")); err != nil {
+		t.Errorf("Repository not checked out in the storage location. %v", err)
+	}
+
+	// Changing a picture and removing other is followed on the next poll
+	repository.writePicture("walls/one.png", 40)
+	repository.run(repository.work, "rm", "--quiet", "walls/nested/two.png")
+	repository.push("Change pictures")
+	updated, err := provider.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 1 || updated[0] == photos[0] || updated[0] == photos[1] {
+		t.Errorf("Expected the changed picture only, got %v after %v", updated, photos)
+	}
+	listed := util.GetPhotosForPath(storage)
+	if len(listed) != 1 || filepath.Base(listed[0]) != filepath.Base(updated[0]) {
+		t.Errorf("Expected only %v left in the storage location, got %v", updated, listed)
+	}
+}
+
+func TestGitProviderRejectsInvalidBranch(t *testing.T) {
+	repository := newGitRepository(t)
+	for _, branch := range []string{"-foo", "--upload-pack=touch pwned", "a..b"} {
+		if provider := GetGitPhotoProvider(map[string]interface{}{"url": repository.bare, "branch": branch}); provider != nil {
+			t.Errorf("Branch %q was accepted", branch)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+const userAgent = "sawyer (+https://github.com/txomon/sawyer)"
+
+// StatusError is a response other than 2xx
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (se StatusError) Error() string {
+	return fmt.Sprintf("GET %v: %v", se.URL, se.Status)
+}
+
+// get requests url with the given headers, failing with StatusError on
+// anything but a 2xx response. The body has to be closed by the caller.
+func get(ctx context.Context, client *http.Client, url string, headers map[string]string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", userAgent)
+	for header, value := range headers {
+		request.Header.Set(header, value)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+		return nil, StatusError{URL: url, StatusCode: response.StatusCode, Status: response.Status}
+	}
+	return response, nil
+}
+
+// getJSON decodes the JSON response to a GET of url into value
+func getJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, value interface{}) error {
+	response, err := get(ctx, client, url, headers)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+		return fmt.Errorf("decoding %v: %v", url, err)
+	}
+	return nil
+}
+
+// nameFromURL makes a provider name out of the host and path of a URL,
+// leaving the query out as it may carry credentials.
+func nameFromURL(rawURL string) string {
+	name := rawURL
+	if parsed, err := url.Parse(rawURL); err == nil && parsed.Host != "" {
+		name = parsed.Host + parsed.Path
+	}
+	return strings.Map(func(char rune) rune {
+		if unicode.IsLetter(char) || unicode.IsNumber(char) {
+			return char
+		}
+		return -1
+	}, name)
+}
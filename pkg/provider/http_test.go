@@ -0,0 +1,15 @@
+package provider
+
+import "testing"
+
+func TestNameFromURL(t *testing.T) {
+	for rawURL, expected := range map[string]string{
+		"https://example.com/api/photos.json?token=secret": "examplecomapiphotosjson",
+		"http://example.com:8080/":                         "examplecom8080",
+		"../../etc":                                        "etc",
+	} {
+		if name := nameFromURL(rawURL); name != expected {
+			t.Errorf("Name of %v is %v, expected %v", rawURL, name, expected)
+		}
+	}
+}
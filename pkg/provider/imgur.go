@@ -2,6 +2,7 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -52,8 +53,8 @@ func (ip *ImgurProvider) imgurRequest(request *http.Request) (interface{}, error
 	return value, err
 }
 
-func (ip *ImgurProvider) imgurGet(endpoint string) (interface{}, error) {
-	request, err := http.NewRequest("GET", buildImgurURL(endpoint), nil)
+func (ip *ImgurProvider) imgurGet(ctx context.Context, endpoint string) (interface{}, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", buildImgurURL(endpoint), nil)
 	if err != nil {
 		logger.Infof("Creating request failed")
 		return nil, err
@@ -62,7 +63,7 @@ func (ip *ImgurProvider) imgurGet(endpoint string) (interface{}, error) {
 	return ip.imgurRequest(request)
 }
 
-func (ip *ImgurProvider) imgurPost(endpoint string, body interface{}) (interface{}, error) {
+func (ip *ImgurProvider) imgurPost(ctx context.Context, endpoint string, body interface{}) (interface{}, error) {
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		logger.Infof("Failed to marshall")
@@ -70,7 +71,7 @@ func (ip *ImgurProvider) imgurPost(endpoint string, body interface{}) (interface
 	}
 
 	jsonReader := bytes.NewReader(jsonBody)
-	request, err := http.NewRequest("POST", buildImgurURL(endpoint), jsonReader)
+	request, err := http.NewRequestWithContext(ctx, "POST", buildImgurURL(endpoint), jsonReader)
 	if err != nil {
 		logger.Infof("Creating request failed")
 		return nil, err
@@ -79,9 +80,9 @@ func (ip *ImgurProvider) imgurPost(endpoint string, body interface{}) (interface
 	return ip.imgurRequest(request)
 }
 
-func (ip *ImgurProvider) imgurPhotosFromGallery(gallery string) ([]string, error) {
+func (ip *ImgurProvider) imgurPhotosFromGallery(ctx context.Context, gallery string) ([]string, error) {
 	albumEndpoint := fmt.Sprintf("/3/gallery/album/%v", gallery)
-	result, err := ip.imgurGet(albumEndpoint)
+	result, err := ip.imgurGet(ctx, albumEndpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -96,9 +97,9 @@ func (ip *ImgurProvider) imgurPhotosFromGallery(gallery string) ([]string, error
 	return imagesUrls, nil
 }
 
-func (ip *ImgurProvider) imgurPhotosFromAlbum(album string) ([]string, error) {
+func (ip *ImgurProvider) imgurPhotosFromAlbum(ctx context.Context, album string) ([]string, error) {
 	albumEndpoint := fmt.Sprintf("/3/album/%v/images", album)
-	result, err := ip.imgurGet(albumEndpoint)
+	result, err := ip.imgurGet(ctx, albumEndpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -111,10 +112,10 @@ func (ip *ImgurProvider) imgurPhotosFromAlbum(album string) ([]string, error) {
 	}
 	return imagesUrls, nil
 }
-func (ip *ImgurProvider) GetPhotos() ([]string, error) {
-	photos, err := ip.imgurPhotosFromAlbum(ip.album)
+func (ip *ImgurProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	photos, err := ip.imgurPhotosFromAlbum(ctx, ip.album)
 	if err != nil {
-		return ip.imgurPhotosFromGallery(ip.album)
+		return ip.imgurPhotosFromGallery(ctx, ip.album)
 	}
 	return photos, err
 }
@@ -126,7 +127,7 @@ func (ip *ImgurProvider) GetName() string {
 func (ip *ImgurProvider) SetStorageLocation(location string) {
 }
 
-func (ip *ImgurProvider) Run(photoProvider *PhotoProvider) {
+func (ip *ImgurProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
 	var pp PhotoProvider = ip
 
 	if photoProvider == nil {
@@ -134,12 +135,14 @@ func (ip *ImgurProvider) Run(photoProvider *PhotoProvider) {
 	}
 
 	for {
-		if photos, err := (*photoProvider).GetPhotos(); err == nil {
+		if photos, err := (*photoProvider).GetPhotos(ctx); err == nil {
 			logger.Debugf("Got %v photos", len(photos))
 		} else {
 			logger.Infof("Failed to get photos from %v. %v", ip.album, err)
 		}
-		time.Sleep(time.Duration(ip.interval))
+		if !sleep(ctx, time.Duration(ip.interval)) {
+			return
+		}
 	}
 }
 
@@ -156,13 +159,12 @@ func GetImgurPhotoProvider(config map[string]interface{}) PhotoProvider {
 	}
 	interval *= 1000000000
 
-	var pl PhotoProvider = &PhotoDownloader{
-		backend: &ImgurProvider{
-			album:    album,
-			interval: interval,
-			client:   http.Client{},
-		},
+	ip := &ImgurProvider{
+		album:    album,
+		interval: interval,
+		client:   http.Client{Timeout: time.Minute},
 	}
+	var pl PhotoProvider = &PhotoDownloader{backend: ip, client: &ip.client}
 
 	return pl
 }
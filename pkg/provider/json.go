@@ -0,0 +1,268 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	paginationNext   = "next"
+	paginationPage   = "page"
+	paginationCursor = "cursor"
+)
+
+var envPlaceholder = regexp.MustCompile(`\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// JSONProvider lists the images of any JSON API. The URL and headers are
+// templates where {env:NAME} is replaced with environment variables and
+// {page} with the page number. Items are selected out of each page with a
+// path, and the image URL and metadata fields out of each item. Pages are
+// followed through a next link field, a page number or a cursor.
+type JSONProvider struct {
+	name       string
+	urlPattern string
+	headers    map[string]string
+	items      jsonPath
+	image      jsonPath
+	metadata   map[string]jsonPath
+	pagination string
+	parameter  string
+	next       jsonPath
+	firstPage  int
+	maxPages   int
+	limit      int
+	interval   time.Duration
+	client     http.Client
+
+	listed map[string]map[string]interface{}
+}
+
+func expandEnv(template string) string {
+	return envPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return os.Getenv(envPlaceholder.FindStringSubmatch(placeholder)[1])
+	})
+}
+
+// pageURL is the URL of the page number given, following cursor when set
+func (jp *JSONProvider) pageURL(page int, cursor string) (string, error) {
+	pageURL := expandEnv(jp.urlPattern)
+	if jp.pagination == paginationPage && strings.Contains(pageURL, "{page}") {
+		return strings.Replace(pageURL, "{page}", fmt.Sprint(page), -1), nil
+	}
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	switch {
+	case jp.pagination == paginationPage:
+		query.Set(jp.parameter, fmt.Sprint(page))
+	case jp.pagination == paginationCursor && cursor != "":
+		query.Set(jp.parameter, cursor)
+	default:
+		return pageURL, nil
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+func (jp *JSONProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	headers := make(map[string]string)
+	for header, value := range jp.headers {
+		headers[header] = expandEnv(value)
+	}
+
+	photos := make([]string, 0)
+	jp.listed = make(map[string]map[string]interface{})
+	pageURL, err := jp.pageURL(jp.firstPage, "")
+	if err != nil {
+		return nil, err
+	}
+	for page := 0; page < jp.maxPages && pageURL != ""; page++ {
+		var document interface{}
+		if err := getJSON(ctx, &jp.client, pageURL, headers, &document); err != nil {
+			if page == 0 {
+				return nil, err
+			}
+			logger.Infof("Stopping at page %v of %v. %v", page, jp.name, err)
+			break
+		}
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			return nil, err
+		}
+		items := jp.items.get(document)
+		for _, item := range items {
+			image := jp.image.getString(item)
+			if image == "" {
+				continue
+			}
+			reference, err := url.Parse(image)
+			if err != nil {
+				logger.Debugf("Skipping invalid image URL %q. %v", image, err)
+				continue
+			}
+			photo := base.ResolveReference(reference).String()
+			if _, ok := jp.listed[photo]; ok {
+				continue
+			}
+			metadata := map[string]interface{}{"source": jp.name}
+			for field, path := range jp.metadata {
+				if value := path.getString(item); value != "" {
+					metadata[field] = value
+				}
+			}
+			jp.listed[photo] = metadata
+			photos = append(photos, photo)
+			if jp.limit > 0 && len(photos) >= jp.limit {
+				return photos, nil
+			}
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		switch jp.pagination {
+		case paginationNext:
+			next := jp.next.getString(document)
+			pageURL = ""
+			if reference, err := url.Parse(next); err == nil && next != "" {
+				pageURL = base.ResolveReference(reference).String()
+			}
+		case paginationCursor:
+			pageURL = ""
+			if cursor := jp.next.getString(document); cursor != "" {
+				if pageURL, err = jp.pageURL(0, cursor); err != nil {
+					return nil, err
+				}
+			}
+		case paginationPage:
+			if pageURL, err = jp.pageURL(jp.firstPage+page+1, ""); err != nil {
+				return nil, err
+			}
+		default:
+			pageURL = ""
+		}
+	}
+	return photos, nil
+}
+
+func (jp *JSONProvider) DescribePhoto(photo string) map[string]interface{} {
+	return jp.listed[photo]
+}
+
+func (jp *JSONProvider) GetName() string {
+	return jp.name
+}
+
+func (jp *JSONProvider) SetStorageLocation(location string) {
+}
+
+func (jp *JSONProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = jp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, jp.interval)
+}
+
+// configPath parses the path expression of a config parameter
+func configPath(config map[string]interface{}, key string) (jsonPath, bool) {
+	expression, ok := config[key].(string)
+	if !ok {
+		return nil, false
+	}
+	path, err := parseJSONPath(expression)
+	if err != nil {
+		logger.Errorf("%v config parameter is not a valid path. %v", key, err)
+		return nil, false
+	}
+	return path, true
+}
+
+func GetJSONPhotoProvider(config map[string]interface{}) PhotoProvider {
+	urlPattern, ok := config["url"].(string)
+	if !ok || urlPattern == "" {
+		logger.Errorf("url config parameter is not a string as expected")
+		return nil
+	}
+	jp := &JSONProvider{
+		name:       fmt.Sprintf("json-%v", nameFromURL(urlPattern)),
+		urlPattern: urlPattern,
+		headers:    make(map[string]string),
+		metadata:   make(map[string]jsonPath),
+		firstPage:  1,
+		maxPages:   1,
+		interval:   pollInterval(config, 3600),
+		client:     http.Client{Timeout: time.Minute},
+	}
+	if name, ok := config["name"].(string); ok && name != "" {
+		jp.name = fmt.Sprintf("json-%v", name)
+	}
+	if headers, ok := config["headers"].(map[string]interface{}); ok {
+		for header, value := range headers {
+			jp.headers[header] = fmt.Sprint(value)
+		}
+	}
+	if _, ok := config["items"]; ok {
+		if jp.items, ok = configPath(config, "items"); !ok {
+			return nil
+		}
+	}
+	if jp.image, ok = configPath(config, "image"); !ok {
+		logger.Errorf("image config parameter has to be the path of the image URL in each item")
+		return nil
+	}
+	if fields, ok := config["metadata"].(map[string]interface{}); ok {
+		for field := range fields {
+			path, ok := configPath(fields, field)
+			if !ok {
+				return nil
+			}
+			jp.metadata[field] = path
+		}
+	}
+
+	if pagination, ok := config["pagination"].(map[string]interface{}); ok {
+		jp.pagination, _ = pagination["type"].(string)
+		jp.maxPages = 5
+		switch jp.pagination {
+		case paginationNext, paginationCursor:
+			if jp.next, ok = configPath(pagination, "path"); !ok {
+				logger.Errorf("%v pagination needs the path of the next link or cursor", jp.pagination)
+				return nil
+			}
+			jp.parameter = "cursor"
+		case paginationPage:
+			jp.parameter = "page"
+			if start, ok := pagination["start"].(float64); ok {
+				jp.firstPage = int(start)
+			}
+		default:
+			logger.Errorf("pagination type %q is not one of %v, %v or %v", jp.pagination, paginationNext, paginationPage, paginationCursor)
+			return nil
+		}
+		if parameter, ok := pagination["parameter"].(string); ok && parameter != "" {
+			jp.parameter = parameter
+		}
+		if maxPages, ok := pagination["max_pages"].(float64); ok && maxPages > 0 {
+			jp.maxPages = int(maxPages)
+		}
+	}
+	if limit, ok := config["limit"].(float64); ok && limit > 0 {
+		jp.limit = int(limit)
+	}
+
+	var pl PhotoProvider = &PhotoDownloader{backend: jp, client: &jp.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("json", GetJSONPhotoProvider)
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// jsonAPI serves three pages of two images, telling the next page in the
+// way the pagination type asks for
+func jsonAPI(t *testing.T, pagination string) (*httptest.Server, *[]string) {
+	return testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		if request.Header.Get("Authorization") != "Bearer secret" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return true
+		}
+		page := 1
+		switch pagination {
+		case paginationNext, paginationCursor:
+			if value := request.URL.Query().Get("after"); value != "" {
+				page, _ = strconv.Atoi(value[len("page"):])
+			}
+		case paginationPage:
+			page, _ = strconv.Atoi(request.URL.Query().Get("p"))
+		}
+		next := ""
+		if page < 3 {
+			switch pagination {
+			case paginationNext:
+				next = fmt.Sprintf("/photos?after=page%v", page+1)
+			case paginationCursor:
+				next = fmt.Sprintf("page%v", page+1)
+			}
+		}
+		items := ""
+		if page <= 3 {
+			items = fmt.Sprintf(`{"src": "/%[1]v-1.jpg", "by": "author %[1]v"}, {"src": "https://example.com/%[1]v-2.jpg"}`, page)
+		}
+		fmt.Fprintf(writer, `{"results": [%v], "next": %q}`, items, next)
+		return true
+	})
+}
+
+func getJSONProvider(t *testing.T, config map[string]interface{}) *JSONProvider {
+	os.Setenv("SAWYER_TEST_TOKEN", "secret")
+	t.Cleanup(func() { os.Unsetenv("SAWYER_TEST_TOKEN") })
+	config["headers"] = map[string]interface{}{"Authorization": "Bearer {env:SAWYER_TEST_TOKEN}"}
+	config["items"] = "results[*]"
+	config["image"] = "src"
+	config["metadata"] = map[string]interface{}{"author": "by"}
+	provider := GetJSONPhotoProvider(config)
+	if provider == nil {
+		t.Fatalf("Config %v was rejected", config)
+	}
+	return provider.(*PhotoDownloader).backend.(*JSONProvider)
+}
+
+func TestJSONProviderPagination(t *testing.T) {
+	tests := []struct {
+		pagination map[string]interface{}
+		requests   []string
+	}{
+		{
+			map[string]interface{}{"type": "next", "path": "next"},
+			[]string{"/photos", "/photos?after=page2", "/photos?after=page3"},
+		},
+		{
+			map[string]interface{}{"type": "cursor", "path": "next", "parameter": "after"},
+			[]string{"/photos", "/photos?after=page2", "/photos?after=page3"},
+		},
+		{
+			// The fourth page is empty, which ends it
+			map[string]interface{}{"type": "page", "parameter": "p"},
+			[]string{"/photos?p=1", "/photos?p=2", "/photos?p=3", "/photos?p=4"},
+		},
+	}
+	for _, test := range tests {
+		pagination := test.pagination["type"].(string)
+		server, requests := jsonAPI(t, pagination)
+		jp := getJSONProvider(t, map[string]interface{}{"url": server.URL + "/photos", "pagination": test.pagination})
+
+		photos, err := jp.GetPhotos(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected []string
+		for page := 1; page <= 3; page++ {
+			expected = append(expected, fmt.Sprintf("%v/%v-1.jpg", server.URL, page), fmt.Sprintf("https://example.com/%v-2.jpg", page))
+		}
+		if !reflect.DeepEqual(photos, expected) {
+			t.Errorf("%v pagination gave %v, expected %v", pagination, photos, expected)
+		}
+		if !reflect.DeepEqual(*requests, test.requests) {
+			t.Errorf("%v pagination requested %v, expected %v", pagination, *requests, test.requests)
+		}
+		metadata := jp.DescribePhoto(expected[2])
+		if metadata["author"] != "author 2" || metadata["source"] != jp.GetName() {
+			t.Errorf("Unexpected metadata %v", metadata)
+		}
+	}
+}
+
+func TestJSONProviderPageTemplateAndLimits(t *testing.T) {
+	server, requests := jsonAPI(t, paginationPage)
+	jp := getJSONProvider(t, map[string]interface{}{
+		"url":        server.URL + "/photos?p={page}",
+		"name":       "test",
+		"pagination": map[string]interface{}{"type": "page", "start": 2.0, "max_pages": 5.0},
+		"limit":      3.0,
+	})
+
+	photos, err := jp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{server.URL + "/2-1.jpg", "https://example.com/2-2.jpg", server.URL + "/3-1.jpg"}
+	if !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected %v, got %v", expected, photos)
+	}
+	if !reflect.DeepEqual(*requests, []string{"/photos?p=2", "/photos?p=3"}) {
+		t.Errorf("Unexpected requests %v", *requests)
+	}
+	if jp.GetName() != "json-test" {
+		t.Errorf("Unexpected name %v", jp.GetName())
+	}
+}
+
+func TestJSONProviderInvalidConfig(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"image": "src"},
+		{"url": "https://example.com"},
+		{"url": "https://example.com", "image": "src[0"},
+		{"url": "https://example.com", "image": "src", "pagination": map[string]interface{}{"type": "offset"}},
+		{"url": "https://example.com", "image": "src", "pagination": map[string]interface{}{"type": "next"}},
+	} {
+		if provider := GetJSONPhotoProvider(config); provider != nil {
+			t.Errorf("Config %v was accepted", config)
+		}
+	}
+}
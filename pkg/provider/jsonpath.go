@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a JSONPath/jq like expression selecting values out of a
+// decoded JSON document: keys separated by dots, [n] to index arrays, ["key"]
+// for keys with dots and [*] or [] to go through every element of an array
+// or value of an object. A leading $ or . is optional.
+type jsonPath []jsonPathSegment
+
+type jsonPathSegment struct {
+	key      string
+	index    int
+	indexed  bool
+	wildcard bool
+}
+
+func parseJSONPath(expression string) (jsonPath, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(expression), "$")
+	var path jsonPath
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in %q", expression)
+			}
+			inside := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inside == "" || inside == "*":
+				path = append(path, jsonPathSegment{wildcard: true})
+			case strings.HasPrefix(inside, `"`) || strings.HasPrefix(inside, "'"):
+				path = append(path, jsonPathSegment{key: strings.Trim(inside, `"'`)})
+			default:
+				index, err := strconv.Atoi(inside)
+				if err != nil {
+					return nil, fmt.Errorf("%q is not an index nor a quoted key in %q", inside, expression)
+				}
+				path = append(path, jsonPathSegment{index: index, indexed: true})
+			}
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if key := rest[:end]; key == "*" {
+				path = append(path, jsonPathSegment{wildcard: true})
+			} else {
+				path = append(path, jsonPathSegment{key: key})
+			}
+			rest = rest[end:]
+		}
+	}
+	return path, nil
+}
+
+// get returns every value the path selects, none when it doesn't match
+func (jp jsonPath) get(document interface{}) []interface{} {
+	values := []interface{}{document}
+	for _, segment := range jp {
+		var next []interface{}
+		for _, value := range values {
+			switch typed := value.(type) {
+			case map[string]interface{}:
+				if segment.wildcard {
+					// Values go in key order, so getString and the photos
+					// listed are the same on every run
+					keys := make([]string, 0, len(typed))
+					for key := range typed {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, typed[key])
+					}
+				} else if child, ok := typed[segment.key]; ok && !segment.indexed {
+					next = append(next, child)
+				}
+			case []interface{}:
+				if segment.wildcard {
+					next = append(next, typed...)
+				} else if segment.indexed {
+					index := segment.index
+					if index < 0 {
+						index += len(typed)
+					}
+					if index >= 0 && index < len(typed) {
+						next = append(next, typed[index])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// getString returns the first value the path selects as text
+func (jp jsonPath) getString(document interface{}) string {
+	for _, value := range jp.get(document) {
+		switch typed := value.(type) {
+		case nil:
+			continue
+		case string:
+			return typed
+		case float64:
+			return strconv.FormatFloat(typed, 'f', -1, 64)
+		default:
+			return fmt.Sprint(typed)
+		}
+	}
+	return ""
+}
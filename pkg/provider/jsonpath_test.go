@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testJSONDocument = `{
+	"data": {
+		"items": [
+			{"image": {"url": "https://example.com/1.jpg"}, "id": 1, "author": null},
+			{"image": {"url": "https://example.com/2.jpg"}, "id": 2.5, "author": "Someone"}
+		],
+		"key.with.dots": "dotted"
+	},
+	"map": {"a": {"url": "a"}}
+}`
+
+func TestJSONPath(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(testJSONDocument), &document); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expression string
+		expected   []interface{}
+	}{
+		{"data.items[*].image.url", []interface{}{"https://example.com/1.jpg", "https://example.com/2.jpg"}},
+		{"$.data.items[].id", []interface{}{1.0, 2.5}},
+		{".data.items.*.id", []interface{}{1.0, 2.5}},
+		{"data.items[0].image.url", []interface{}{"https://example.com/1.jpg"}},
+		{"data.items[-1].id", []interface{}{2.5}},
+		{"data.items[5].id", nil},
+		{`data["key.with.dots"]`, []interface{}{"dotted"}},
+		{"data['key.with.dots']", []interface{}{"dotted"}},
+		{"map[*].url", []interface{}{"a"}},
+		{"data.missing", nil},
+		{"data.items.id", nil},
+		{"", []interface{}{document}},
+	}
+	for _, test := range tests {
+		path, err := parseJSONPath(test.expression)
+		if err != nil {
+			t.Errorf("Parsing %q failed. %v", test.expression, err)
+			continue
+		}
+		if got := path.get(document); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q selected %v, expected %v", test.expression, got, test.expected)
+		}
+	}
+
+	for expression, expected := range map[string]string{
+		"data.items[*].author": "Someone",
+		"data.items[1].id":     "2.5",
+		"data.items[0].id":     "1",
+		"data.missing":         "",
+	} {
+		path, _ := parseJSONPath(expression)
+		if got := path.getString(document); got != expected {
+			t.Errorf("%q gave %q, expected %q", expression, got, expected)
+		}
+	}
+}
+
+func TestJSONPathInvalid(t *testing.T) {
+	for _, expression := range []string{"data.items[0", "data[one]"} {
+		if path, err := parseJSONPath(expression); err == nil {
+			t.Errorf("%q parsed as %v", expression, path)
+		}
+	}
+}
+
+func TestJSONPathWildcardKeyOrder(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(`{"photos": {"d": "4", "b": "2", "a": "1", "c": "3", "e": "5"}}`), &document); err != nil {
+		t.Fatal(err)
+	}
+	path, err := parseJSONPath("photos.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Map iteration is random, a few runs would catch it leaking through
+	for run := 0; run < 20; run++ {
+		if values := path.get(document); !reflect.DeepEqual(values, []interface{}{"1", "2", "3", "4", "5"}) {
+			t.Fatalf("Values not in key order, %v", values)
+		}
+		if first := path.getString(document); first != "1" {
+			t.Fatalf("Expected the value of the first key, got %v", first)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package provider
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/txomon/sawyer/pkg/util"
 )
@@ -14,18 +17,21 @@ type PhotoLinker struct {
 	backend        PhotoProvider
 	cacheDirectory string
 	memory         MapMemory
+	// prune removes the photos the backend doesn't list anymore
+	prune bool
 }
 
-func (pl *PhotoLinker) Run(photoProvider *PhotoProvider) {
+func (pl *PhotoLinker) Run(ctx context.Context, photoProvider *PhotoProvider) {
 	var pp PhotoProvider = pl
 	if photoProvider == nil {
 		photoProvider = &pp
 	}
-	pl.backend.Run(photoProvider)
+	pl.backend.Run(ctx, photoProvider)
 }
 func (pl *PhotoLinker) SetStorageLocation(cacheDirectory string) {
 	pl.cacheDirectory = cacheDirectory
 	pl.memory = NewMemory(cacheDirectory)
+	pl.backend.SetStorageLocation(cacheDirectory)
 }
 
 func (pl *PhotoLinker) String() string {
@@ -35,11 +41,11 @@ func (pl *PhotoLinker) GetName() string {
 	return pl.backend.GetName()
 }
 
-func (pl *PhotoLinker) GetPhotos() ([]string, error) {
+func (pl *PhotoLinker) GetPhotos(ctx context.Context) ([]string, error) {
 	logger.Tracef("Storagedirectory to %v, %p", pl.cacheDirectory, &pl)
 	photos := make([]string, 0)
 
-	backendPhotos, err := pl.backend.GetPhotos()
+	backendPhotos, err := pl.backend.GetPhotos(ctx)
 	if err != nil {
 		logger.Infof("Failed to get photos from %v. Doing nothing", pl.backend)
 		return photos, err
@@ -47,8 +53,18 @@ func (pl *PhotoLinker) GetPhotos() ([]string, error) {
 	logger.Tracef("Getting photos and storing them in %v", pl.cacheDirectory)
 
 	for _, backendPhotoPath := range backendPhotos {
+		if ctx.Err() != nil {
+			logger.Debugf("Stopping linking from %v", pl.backend.GetName())
+			break
+		}
 		logger.Tracef("Procesing photo %v", backendPhotoPath)
-		if cachedFile := pl.memory.getMemory(backendPhotoPath); cachedFile != "" {
+		memoryKey := backendPhotoPath
+		if versioner, ok := pl.backend.(PhotoVersioner); ok {
+			if version := versioner.PhotoVersion(backendPhotoPath); version != "" {
+				memoryKey = fmt.Sprintf("%v#%v", backendPhotoPath, version)
+			}
+		}
+		if cachedFile := pl.memory.getMemory(memoryKey); cachedFile != "" {
 			if _, err := os.Stat(cachedFile); err == nil {
 				photos = append(photos, cachedFile)
 				logger.Tracef("Cached file, nothing needs to be done")
@@ -91,27 +107,71 @@ func (pl *PhotoLinker) GetPhotos() ([]string, error) {
 				logger.Warningf("The to-be-linked exists and is a directory! %v", photoPath)
 			}
 			logger.Debugf("File %v exists, doing nothing.", photoPath)
+			copyMetadata(backendPhotoPath, photoPath)
 			photos = append(photos, photoPath)
-			pl.memory.setMemory(backendPhotoPath, photoPath)
+			pl.memory.setMemory(memoryKey, photoPath)
 			continue
 		}
 
 		if err := os.Link(backendPhotoPath, photoPath); err != nil {
 			logger.Debugf("Failed to link file, copying it. %v", err)
-			if photoFile, err := os.Create(photoPath); err != nil {
-				logger.Warningf("Failed to create file in cache dir %v", photoPath)
+			if err := util.WriteFileAtomic(photoPath, backendPhotoContent); err != nil {
+				logger.Warningf("Failed to write file in cache dir %v. %v", photoPath, err)
 				continue
-			} else {
-				go func() {
-					photoFile.Write(backendPhotoContent)
-					photoFile.Close()
-				}()
 			}
 		} else {
 			logger.Tracef("Linked file %v to %v", backendPhotoPath, photoPath)
 		}
+		copyMetadata(backendPhotoPath, photoPath)
 		photos = append(photos, photoPath)
-		pl.memory.setMemory(backendPhotoPath, photoPath)
+		pl.memory.setMemory(memoryKey, photoPath)
+	}
+	if pl.prune && ctx.Err() == nil {
+		pl.removeUnlisted(photos)
 	}
 	return photos, nil
 }
+
+// removeUnlisted deletes the photos in the cache directory, and their
+// metadata, that are not among the ones given
+func (pl *PhotoLinker) removeUnlisted(photos []string) {
+	listed := make(map[string]bool)
+	for _, photo := range photos {
+		listed[photo] = true
+	}
+	entries, err := ioutil.ReadDir(pl.cacheDirectory)
+	if err != nil {
+		logger.Infof("Failed to list %v for removed photos. %v", pl.cacheDirectory, err)
+		return
+	}
+	for _, entry := range entries {
+		photoPath := filepath.Join(pl.cacheDirectory, entry.Name())
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || util.IsMetadata(photoPath) ||
+			photoPath == pl.memory.memoryFile || listed[photoPath] {
+			continue
+		}
+		logger.Debugf("Removing %v, %v doesn't list it anymore", photoPath, pl.backend.GetName())
+		if err := os.Remove(photoPath); err != nil {
+			logger.Warningf("Failed to remove %v. %v", photoPath, err)
+			continue
+		}
+		if err := os.Remove(photoPath + util.MetadataExtension); err != nil && !os.IsNotExist(err) {
+			logger.Infof("Failed to remove metadata of %v. %v", photoPath, err)
+		}
+	}
+}
+
+// copyMetadata keeps the sidecar metadata of a photo along its cached copy
+func copyMetadata(from, to string) {
+	metadata, err := util.ReadMetadata(from)
+	if err != nil {
+		logger.Infof("Failed to read metadata of %v. %v", from, err)
+		return
+	}
+	if metadata == nil {
+		return
+	}
+	if err := util.WriteMetadata(to, metadata); err != nil {
+		logger.Warningf("Failed to write metadata of %v. %v", to, err)
+	}
+}
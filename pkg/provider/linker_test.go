@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+func TestPhotoLinkerKeepsMetadata(t *testing.T) {
+	util.RegisterSupportedFormat("jpg")
+	source := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := ioutil.WriteFile(source, []byte("photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteMetadata(source, map[string]interface{}{"title": "Photo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pl := &PhotoLinker{backend: &listProvider{photos: []string{source}}}
+	pl.SetStorageLocation(t.TempDir())
+	photos, err := pl.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 1 {
+		t.Fatalf("Expected the photo to be linked, got %v", photos)
+	}
+	if metadata, err := util.ReadMetadata(photos[0]); err != nil || metadata["title"] != "Photo" {
+		t.Errorf("Metadata not kept along %v, %v %v", photos[0], metadata, err)
+	}
+}
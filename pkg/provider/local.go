@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -20,19 +21,21 @@ func (lpp *LocalPhotoProvider) String() string {
 func (lpp *LocalPhotoProvider) SetStorageLocation(cacheDirectory string) {
 }
 
-func (lpp *LocalPhotoProvider) Run(photoProvider *PhotoProvider) {
+func (lpp *LocalPhotoProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
 	var pp PhotoProvider = lpp
 	if photoProvider == nil {
 		photoProvider = &pp
 	}
 	logger.Debugf("Running %v with %v", lpp, photoProvider)
 	for {
-		if photos, err := (*photoProvider).GetPhotos(); err == nil {
+		if photos, err := (*photoProvider).GetPhotos(ctx); err == nil {
 			logger.Debugf("Got %v photos", len(photos))
 		} else {
 			logger.Infof("Failed to use photos from %v. %v", lpp.path, err)
 		}
-		time.Sleep(time.Duration(lpp.interval))
+		if !sleep(ctx, time.Duration(lpp.interval)) {
+			return
+		}
 	}
 }
 func (lpp *LocalPhotoProvider) GetName() string {
@@ -45,7 +48,7 @@ func (lpp *LocalPhotoProvider) GetName() string {
 	return fmt.Sprintf("local-%v", name)
 }
 
-func (lpp *LocalPhotoProvider) GetPhotos() ([]string, error) {
+func (lpp *LocalPhotoProvider) GetPhotos(ctx context.Context) ([]string, error) {
 	photos := util.GetPhotosForPath(lpp.path)
 	return photos, nil
 }
@@ -1,6 +1,10 @@
 package provider
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/juju/loggo"
 	"github.com/txomon/sawyer/pkg/util"
 )
@@ -8,9 +12,9 @@ import (
 var logger = loggo.GetLogger("sawyer.provider")
 
 type PhotoProvider interface {
-	GetPhotos() ([]string, error)
+	GetPhotos(context.Context) ([]string, error)
 	GetName() string
-	Run(*PhotoProvider)
+	Run(context.Context, *PhotoProvider)
 	SetStorageLocation(string)
 }
 
@@ -38,7 +42,21 @@ func GetProvider(config map[string]interface{}) PhotoProvider {
 	return provider
 }
 
-func RunProviders(cacheDirectory string, configs []interface{}) {
+// sleep waits for duration, returning false if ctx is done before that.
+func sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// RunProviders starts every configured provider until ctx is done, adding
+// them to running so the caller can wait for their downloads to finish.
+func RunProviders(ctx context.Context, running *sync.WaitGroup, cacheDirectory string, configs []interface{}) {
 	logger.Debugf("Providers registered %v", registeredProviders)
 	logger.Debugf("Config %v", configs)
 	for _, interfaceConfig := range configs {
@@ -50,7 +68,45 @@ func RunProviders(cacheDirectory string, configs []interface{}) {
 			storageDir := util.CreateStorageDir(cacheDirectory, provider.GetName())
 			logger.Debugf("Setting %v(%p) storage dir %v", provider, &provider, storageDir)
 			provider.SetStorageLocation(storageDir)
-			go provider.Run(nil)
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				provider.Run(ctx, nil)
+				logger.Debugf("Provider %v stopped", provider.GetName())
+			}()
+		}
+	}
+}
+
+// pollInterval reads the poll_interval in seconds out of a provider config,
+// where JSON numbers are decoded as float64.
+func pollInterval(config map[string]interface{}, defaultSeconds float64) time.Duration {
+	seconds, ok := config["poll_interval"].(float64)
+	if !ok || seconds <= 0 {
+		seconds = defaultSeconds
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// poll gets the photos of photoProvider every interval until ctx is done
+func poll(ctx context.Context, photoProvider PhotoProvider, interval time.Duration) {
+	for {
+		if photos, err := photoProvider.GetPhotos(ctx); err == nil {
+			logger.Debugf("Got %v photos from %v", len(photos), photoProvider.GetName())
+		} else {
+			logger.Infof("Failed to get photos from %v. %v", photoProvider.GetName(), err)
+		}
+		if !sleep(ctx, interval) {
+			return
+		}
+	}
+}
+
+func isOneOf(value string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
 		}
 	}
+	return false
 }
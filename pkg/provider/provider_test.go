@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testPicture is a PNG telling path apart from any other, so every path
+// is stored as a different photo.
+func testPicture(path string) []byte {
+	picture := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < len(path) && x < 16; x++ {
+		picture.Set(x%4, x/4, color.RGBA{R: uint8(len(path)), G: path[x], A: 255})
+	}
+	var content bytes.Buffer
+	png.Encode(&content, picture)
+	return content.Bytes()
+}
+
+// testServer records the request URIs it gets, answering them with
+// respond. When respond returns false a picture of the path is served.
+func testServer(t *testing.T, respond func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool) (*httptest.Server, *[]string) {
+	var requests []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests = append(requests, request.URL.RequestURI())
+		if !respond(server, writer, request) {
+			writer.Write(testPicture(request.URL.Path))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestPollInterval(t *testing.T) {
+	for _, test := range []struct {
+		config   map[string]interface{}
+		expected time.Duration
+	}{
+		{map[string]interface{}{"poll_interval": 1.5}, 1500 * time.Millisecond},
+		{map[string]interface{}{"poll_interval": -1.0}, time.Hour},
+		{map[string]interface{}{"poll_interval": "60"}, time.Hour},
+		{map[string]interface{}{}, time.Hour},
+	} {
+		if interval := pollInterval(test.config, 3600); interval != test.expected {
+			t.Errorf("Interval of %v is %v, expected %v", test.config, interval, test.expected)
+		}
+	}
+}
+
+// countingProvider counts how many times photos are got, cancelling once
+// it reaches limit
+type countingProvider struct {
+	listProvider
+	count  int
+	limit  int
+	cancel context.CancelFunc
+}
+
+func (cp *countingProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	cp.count++
+	if cp.count == cp.limit {
+		cp.cancel()
+	}
+	return nil, nil
+}
+
+func TestPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cp := &countingProvider{limit: 3, cancel: cancel}
+	done := make(chan struct{})
+	go func() {
+		poll(ctx, cp, time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Polling didn't stop once cancelled")
+	}
+	if cp.count != 3 {
+		t.Errorf("Expected 3 polls, got %v", cp.count)
+	}
+}
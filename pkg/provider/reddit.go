@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+var redditSorts = []string{"hot", "top", "new"}
+
+var redditTimes = []string{"hour", "day", "week", "month", "year", "all"}
+
+// RedditProvider lists the pictures posted to a subreddit, both direct
+// image links and galleries. NSFW and spoiler posts are skipped unless
+// enabled.
+type RedditProvider struct {
+	baseURL   string
+	subreddit string
+	sort      string
+	time      string
+	limit     int
+	nsfw      bool
+	spoilers  bool
+	interval  time.Duration
+	client    http.Client
+}
+
+type redditListing struct {
+	Data struct {
+		Children []struct {
+			Data redditPost `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+type redditPost struct {
+	URL           string `json:"url"`
+	Over18        bool   `json:"over_18"`
+	Spoiler       bool   `json:"spoiler"`
+	IsGallery     bool   `json:"is_gallery"`
+	MediaMetadata map[string]struct {
+		Status string `json:"status"`
+		Type   string `json:"e"`
+		Source struct {
+			URL string `json:"u"`
+		} `json:"s"`
+	} `json:"media_metadata"`
+	GalleryData struct {
+		Items []struct {
+			MediaID string `json:"media_id"`
+		} `json:"items"`
+	} `json:"gallery_data"`
+}
+
+func (rp *RedditProvider) listingURL() string {
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(rp.limit))
+	query.Set("raw_json", "1")
+	if rp.sort == "top" {
+		query.Set("t", rp.time)
+	}
+	return fmt.Sprintf("%v/r/%v/%v.json?%v", rp.baseURL, url.PathEscape(rp.subreddit), rp.sort, query.Encode())
+}
+
+// isImageURL tells direct links to pictures apart from links to pages
+func isImageURL(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(path.Ext(parsed.Path)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// postPhotos are the pictures of a post, the gallery ones in order
+func (rp *RedditProvider) postPhotos(post redditPost) []string {
+	if !post.IsGallery {
+		if isImageURL(post.URL) {
+			return []string{post.URL}
+		}
+		return nil
+	}
+	var photos []string
+	for _, item := range post.GalleryData.Items {
+		media, ok := post.MediaMetadata[item.MediaID]
+		if !ok || media.Status != "valid" || media.Type != "Image" || media.Source.URL == "" {
+			continue
+		}
+		photos = append(photos, html.UnescapeString(media.Source.URL))
+	}
+	return photos
+}
+
+func (rp *RedditProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	var listing redditListing
+	if err := getJSON(ctx, &rp.client, rp.listingURL(), nil, &listing); err != nil {
+		return nil, err
+	}
+	photos := make([]string, 0)
+	for _, child := range listing.Data.Children {
+		post := child.Data
+		if post.Over18 && !rp.nsfw || post.Spoiler && !rp.spoilers {
+			logger.Tracef("Skipping NSFW or spoiler post %v", post.URL)
+			continue
+		}
+		photos = append(photos, rp.postPhotos(post)...)
+	}
+	return photos, nil
+}
+
+func (rp *RedditProvider) GetName() string {
+	return fmt.Sprintf("reddit-%v-%v", nameFromURL(rp.subreddit), rp.sort)
+}
+
+func (rp *RedditProvider) SetStorageLocation(location string) {
+}
+
+func (rp *RedditProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = rp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, rp.interval)
+}
+
+func GetRedditPhotoProvider(config map[string]interface{}) PhotoProvider {
+	subreddit, ok := config["subreddit"].(string)
+	if !ok || subreddit == "" {
+		logger.Errorf("subreddit config parameter is not a string as expected")
+		return nil
+	}
+	rp := &RedditProvider{
+		baseURL:   "https://www.reddit.com",
+		subreddit: subreddit,
+		sort:      "hot",
+		time:      "day",
+		limit:     25,
+		interval:  pollInterval(config, 3600),
+		client:    http.Client{Timeout: time.Minute},
+	}
+	if baseURL, ok := config["base_url"].(string); ok {
+		rp.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if sort, ok := config["sort"].(string); ok {
+		if !isOneOf(sort, redditSorts) {
+			logger.Errorf("sort config parameter %q is not one of %v", sort, redditSorts)
+			return nil
+		}
+		rp.sort = sort
+	}
+	if window, ok := config["time"].(string); ok {
+		if !isOneOf(window, redditTimes) {
+			logger.Errorf("time config parameter %q is not one of %v", window, redditTimes)
+			return nil
+		}
+		rp.time = window
+	}
+	if limit, ok := config["limit"].(float64); ok && limit > 0 {
+		rp.limit = int(limit)
+	}
+	rp.nsfw, _ = config["nsfw"].(bool)
+	rp.spoilers, _ = config["spoilers"].(bool)
+
+	var pl PhotoProvider = &PhotoDownloader{backend: rp, client: &rp.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("reddit", GetRedditPhotoProvider)
+}
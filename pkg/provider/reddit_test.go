@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+const testRedditListing = `{"data": {"children": [
+	{"data": {"url": "%[1]v/direct.png"}},
+	{"data": {"url": "%[1]v/page.html"}},
+	{"data": {"url": "%[1]v/nsfw.png", "over_18": true}},
+	{"data": {"url": "%[1]v/spoiler.png", "spoiler": true}},
+	{"data": {"url": "%[1]v/gallery/1", "is_gallery": true,
+		"gallery_data": {"items": [{"media_id": "b"}, {"media_id": "a"}, {"media_id": "missing"}]},
+		"media_metadata": {
+			"a": {"status": "valid", "e": "Image", "s": {"u": "%[1]v/a.png?width=10&amp;s=1"}},
+			"b": {"status": "valid", "e": "Image", "s": {"u": "%[1]v/b.png"}}
+		}}}
+]}}`
+
+// redditServer serves the listing for every subreddit
+func redditServer(t *testing.T) (*httptest.Server, *[]string) {
+	return testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		if !strings.HasPrefix(request.URL.Path, "/r/") {
+			return false
+		}
+		fmt.Fprintf(writer, testRedditListing, server.URL)
+		return true
+	})
+}
+
+func TestRedditProvider(t *testing.T) {
+	util.RegisterSupportedFormat("png")
+	server, requests := redditServer(t)
+	provider := GetRedditPhotoProvider(map[string]interface{}{
+		"base_url":  server.URL + "/",
+		"subreddit": "wallpapers",
+		"sort":      "top",
+		"time":      "week",
+		"limit":     10.0,
+	})
+	storage := t.TempDir()
+	provider.SetStorageLocation(storage)
+
+	photos, err := provider.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 3 {
+		t.Errorf("Expected 3 photos, got %v", photos)
+	}
+	expected := []string{
+		"/r/wallpapers/top.json?limit=10&raw_json=1&t=week",
+		"/direct.png",
+		"/b.png",
+		"/a.png?width=10&s=1",
+	}
+	if !reflect.DeepEqual(*requests, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, *requests)
+	}
+	if listed := util.GetPhotosForPath(storage); len(listed) != 3 {
+		t.Errorf("Expected 3 photos stored, got %v", listed)
+	}
+}
+
+func TestRedditProviderNSFWAndSpoilers(t *testing.T) {
+	server, _ := redditServer(t)
+	rp := GetRedditPhotoProvider(map[string]interface{}{
+		"base_url":  server.URL,
+		"subreddit": "wallpapers",
+		"nsfw":      true,
+		"spoilers":  true,
+	}).(*PhotoDownloader).backend
+
+	photos, err := rp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 5 || photos[1] != server.URL+"/nsfw.png" || photos[2] != server.URL+"/spoiler.png" {
+		t.Errorf("Expected NSFW and spoiler posts, got %v", photos)
+	}
+}
+
+func TestRedditProviderRejectsInvalidSort(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"subreddit": "wallpapers", "sort": "best"},
+		{"subreddit": "wallpapers", "time": "decade"},
+		{"sort": "hot"},
+	} {
+		if provider := GetRedditPhotoProvider(config); provider != nil {
+			t.Errorf("Config %v was accepted", config)
+		}
+	}
+}
+
+func TestRedditProviderNameIsPathSafe(t *testing.T) {
+	rp := &RedditProvider{subreddit: "../earth porn", sort: "top"}
+	if name := rp.GetName(); name != "reddit-earthporn-top" {
+		t.Errorf("Unexpected name %v", name)
+	}
+}
@@ -0,0 +1,307 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Hash of an empty payload, as every request signed is a GET
+const s3EmptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Now is the time requests are signed at, stubbed out when testing
+var s3Now = time.Now
+
+// S3Provider lists the pictures under a prefix of an S3 bucket, or any
+// S3-compatible storage like MinIO. Requests are signed with AWS Signature
+// Version 4 when there are credentials, and objects are only downloaded
+// again when their ETag changes.
+type S3Provider struct {
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	prefix       string
+	pathStyle    bool
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	interval     time.Duration
+	client       http.Client
+
+	listed map[string]s3Object
+}
+
+type s3Object struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+}
+
+type s3ListBucketResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+// bucketURL is the URL of the bucket, either as a path of the endpoint or as
+// a subdomain of it
+func (sp *S3Provider) bucketURL() *url.URL {
+	bucketURL := *sp.endpoint
+	if sp.pathStyle {
+		bucketURL.Path = strings.TrimSuffix(bucketURL.Path, "/") + "/" + sp.bucket
+	} else {
+		bucketURL.Host = sp.bucket + "." + bucketURL.Host
+	}
+	return &bucketURL
+}
+
+func (sp *S3Provider) objectURL(key string) string {
+	return strings.TrimSuffix(sp.bucketURL().String(), "/") + "/" + s3Escape(key, false)
+}
+
+// s3Escape URI encodes the way SigV4 asks for, which leaves less
+// characters alone than url.QueryEscape
+func s3Escape(value string, escapeSlash bool) string {
+	var escaped strings.Builder
+	for _, char := range []byte(value) {
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9',
+			char == '-', char == '_', char == '.', char == '~', char == '/' && !escapeSlash:
+			escaped.WriteByte(char)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", char)
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// DecorateRequest signs the request with AWS Signature Version 4, leaving it
+// anonymous when there are no credentials.
+func (sp *S3Provider) DecorateRequest(request *http.Request) error {
+	if sp.accessKey == "" || sp.secretKey == "" {
+		return nil
+	}
+	now := s3Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", s3EmptyPayload)
+	if sp.sessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", sp.sessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for header, values := range request.Header {
+		header = strings.ToLower(header)
+		if strings.HasPrefix(header, "x-amz-") {
+			headers[header] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	var names []string
+	for header := range headers {
+		names = append(names, header)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, header := range names {
+		fmt.Fprintf(&canonicalHeaders, "%v:%v\n", header, headers[header])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := request.URL.Query()
+	var parameters []string
+	for parameter, values := range query {
+		for _, value := range values {
+			parameters = append(parameters, s3Escape(parameter, true)+"="+s3Escape(value, true))
+		}
+	}
+	sort.Strings(parameters)
+	canonicalPath := request.URL.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		canonicalPath,
+		strings.Join(parameters, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3EmptyPayload,
+	}, "\n")
+	// The query is sent as it was signed
+	request.URL.RawQuery = strings.Join(parameters, "&")
+
+	scope := fmt.Sprintf("%v/%v/s3/aws4_request", date, sp.region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+	key := hmacSHA256([]byte("AWS4"+sp.secretKey), date)
+	key = hmacSHA256(key, sp.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		sp.accessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+// list returns a page of objects, starting at the continuation token given
+func (sp *S3Provider) list(ctx context.Context, token string) (*s3ListBucketResult, error) {
+	listURL := sp.bucketURL()
+	if listURL.Path == "" {
+		listURL.Path = "/"
+	}
+	query := url.Values{}
+	query.Set("list-type", "2")
+	if sp.prefix != "" {
+		query.Set("prefix", sp.prefix)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	listURL.RawQuery = query.Encode()
+	request, err := http.NewRequestWithContext(ctx, "GET", listURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", userAgent)
+	if err := sp.DecorateRequest(request); err != nil {
+		return nil, err
+	}
+	response, err := sp.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, StatusError{URL: listURL.String(), StatusCode: response.StatusCode, Status: response.Status}
+	}
+	var result s3ListBucketResult
+	if err := xml.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("parsing listing of %v: %v", sp.bucket, err)
+	}
+	return &result, nil
+}
+
+func (sp *S3Provider) GetPhotos(ctx context.Context) ([]string, error) {
+	photos := make([]string, 0)
+	listed := make(map[string]s3Object)
+	token := ""
+	for {
+		result, err := sp.list(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			photo := sp.objectURL(object.Key)
+			if !isImageURL(photo) {
+				continue
+			}
+			listed[photo] = object
+			photos = append(photos, photo)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sp.listed = listed
+	return photos, nil
+}
+
+func (sp *S3Provider) PhotoVersion(photo string) string {
+	return strings.Trim(sp.listed[photo].ETag, `"`)
+}
+
+func (sp *S3Provider) DescribePhoto(photo string) map[string]interface{} {
+	object, ok := sp.listed[photo]
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"source": "s3",
+		"bucket": sp.bucket,
+		"key":    object.Key,
+		"etag":   strings.Trim(object.ETag, `"`),
+	}
+}
+
+func (sp *S3Provider) GetName() string {
+	name := strings.Map(func(char rune) rune {
+		if unicode.IsLetter(char) || unicode.IsNumber(char) || char == '-' {
+			return char
+		}
+		return -1
+	}, sp.bucket+"-"+sp.prefix)
+	return fmt.Sprintf("s3-%v", strings.TrimSuffix(name, "-"))
+}
+
+func (sp *S3Provider) SetStorageLocation(location string) {
+}
+
+func (sp *S3Provider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = sp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, sp.interval)
+}
+
+func GetS3PhotoProvider(config map[string]interface{}) PhotoProvider {
+	bucket, ok := config["bucket"].(string)
+	if !ok || bucket == "" {
+		logger.Errorf("bucket config parameter is not a string as expected")
+		return nil
+	}
+	sp := &S3Provider{
+		region:       "us-east-1",
+		bucket:       bucket,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		interval:     pollInterval(config, 3600),
+		client:       http.Client{Timeout: time.Minute},
+	}
+	if region, ok := config["region"].(string); ok && region != "" {
+		sp.region = region
+	}
+	sp.prefix, _ = config["prefix"].(string)
+	sp.pathStyle, _ = config["path_style"].(bool)
+	if accessKey, ok := config["access_key"].(string); ok && accessKey != "" {
+		sp.accessKey = accessKey
+		sp.secretKey, _ = config["secret_key"].(string)
+		sp.sessionToken, _ = config["session_token"].(string)
+	}
+
+	endpoint := fmt.Sprintf("https://s3.%v.amazonaws.com", sp.region)
+	if configured, ok := config["endpoint"].(string); ok && configured != "" {
+		endpoint = configured
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		logger.Errorf("endpoint config parameter %q is not an absolute URL. %v", endpoint, err)
+		return nil
+	}
+	sp.endpoint = parsed
+
+	var pl PhotoProvider = &PhotoDownloader{backend: sp, client: &sp.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("s3", GetS3PhotoProvider)
+}
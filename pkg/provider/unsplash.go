@@ -0,0 +1,276 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var unsplashOrientations = []string{"landscape", "portrait", "squarish"}
+
+// Providers don't know the outputs pictures end up on, so unless width is
+// configured photos are requested as wide as the most common displays.
+// Unsplash keeps the aspect ratio, and backends scale them to the output.
+const unsplashDefaultWidth = 1920
+
+// UnsplashProvider lists photos from a collection, a topic, a search or the
+// random endpoint, which can be narrowed by all of them at once. Photos are
+// requested at the configured size instead of the raw file, and every one
+// downloaded is reported to Unsplash and keeps its attribution.
+type UnsplashProvider struct {
+	baseURL     string
+	apiKey      string
+	random      bool
+	collection  string
+	topic       string
+	query       string
+	orientation string
+	count       int
+	width       int
+	height      int
+	interval    time.Duration
+	client      http.Client
+
+	listed map[string]unsplashPhoto
+}
+
+type unsplashPhoto struct {
+	ID             string `json:"id"`
+	Description    string `json:"description"`
+	AltDescription string `json:"alt_description"`
+	URLs           struct {
+		Raw string `json:"raw"`
+	} `json:"urls"`
+	Links struct {
+		HTML             string `json:"html"`
+		DownloadLocation string `json:"download_location"`
+	} `json:"links"`
+	User struct {
+		Name  string `json:"name"`
+		Links struct {
+			HTML string `json:"html"`
+		} `json:"links"`
+	} `json:"user"`
+}
+
+func (up *UnsplashProvider) headers() map[string]string {
+	return map[string]string{
+		"Authorization":  fmt.Sprintf("Client-ID %v", up.apiKey),
+		"Accept-Version": "v1",
+	}
+}
+
+// endpoint is the API endpoint listing the photos, and whether it answers
+// with search results instead of a list of photos.
+func (up *UnsplashProvider) endpoint() (string, bool) {
+	query := url.Values{}
+	if up.orientation != "" {
+		query.Set("orientation", up.orientation)
+	}
+	switch {
+	case up.random:
+		query.Set("count", fmt.Sprint(up.count))
+		if up.collection != "" {
+			query.Set("collections", up.collection)
+		}
+		if up.topic != "" {
+			query.Set("topics", up.topic)
+		}
+		if up.query != "" {
+			query.Set("query", up.query)
+		}
+		return fmt.Sprintf("%v/photos/random?%v", up.baseURL, query.Encode()), false
+	case up.collection != "":
+		query.Set("per_page", fmt.Sprint(up.count))
+		return fmt.Sprintf("%v/collections/%v/photos?%v", up.baseURL, url.PathEscape(up.collection), query.Encode()), false
+	case up.topic != "":
+		query.Set("per_page", fmt.Sprint(up.count))
+		return fmt.Sprintf("%v/topics/%v/photos?%v", up.baseURL, url.PathEscape(up.topic), query.Encode()), false
+	default:
+		query.Set("per_page", fmt.Sprint(up.count))
+		query.Set("query", up.query)
+		return fmt.Sprintf("%v/search/photos?%v", up.baseURL, query.Encode()), true
+	}
+}
+
+// sized asks Unsplash to scale the raw photo down to the configured size
+func (up *UnsplashProvider) sized(raw string) (string, error) {
+	photoURL, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	query := photoURL.Query()
+	query.Set("fm", "jpg")
+	query.Set("q", "85")
+	query.Set("w", fmt.Sprint(up.width))
+	if up.height > 0 {
+		query.Set("h", fmt.Sprint(up.height))
+		query.Set("fit", "crop")
+	} else {
+		query.Set("fit", "max")
+	}
+	photoURL.RawQuery = query.Encode()
+	return photoURL.String(), nil
+}
+
+func (up *UnsplashProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	endpoint, search := up.endpoint()
+	var listed []unsplashPhoto
+	if search {
+		var results struct {
+			Results []unsplashPhoto `json:"results"`
+		}
+		if err := getJSON(ctx, &up.client, endpoint, up.headers(), &results); err != nil {
+			return nil, err
+		}
+		listed = results.Results
+	} else if err := getJSON(ctx, &up.client, endpoint, up.headers(), &listed); err != nil {
+		return nil, err
+	}
+
+	photos := make([]string, 0)
+	up.listed = make(map[string]unsplashPhoto)
+	for _, photo := range listed {
+		sized, err := up.sized(photo.URLs.Raw)
+		if err != nil {
+			logger.Infof("Skipping Unsplash photo %v with an invalid URL. %v", photo.ID, err)
+			continue
+		}
+		up.listed[sized] = photo
+		photos = append(photos, sized)
+	}
+	return photos, nil
+}
+
+// referral adds the parameters Unsplash asks links back to it to have
+func referral(link string) string {
+	if link == "" {
+		return ""
+	}
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+	return link + separator + "utm_source=sawyer&utm_medium=referral"
+}
+
+func (up *UnsplashProvider) DescribePhoto(photo string) map[string]interface{} {
+	listed, ok := up.listed[photo]
+	if !ok {
+		return nil
+	}
+	title := listed.Description
+	if title == "" {
+		title = listed.AltDescription
+	}
+	return map[string]interface{}{
+		"source":     "unsplash",
+		"id":         listed.ID,
+		"title":      title,
+		"author":     listed.User.Name,
+		"author_url": referral(listed.User.Links.HTML),
+		"url":        referral(listed.Links.HTML),
+		"license":    "Unsplash License",
+	}
+}
+
+// TrackDownload hits the download location of the photo, which Unsplash
+// requires whenever one of its photos is used.
+func (up *UnsplashProvider) TrackDownload(ctx context.Context, photo string) error {
+	listed, ok := up.listed[photo]
+	if !ok || listed.Links.DownloadLocation == "" {
+		return nil
+	}
+	response, err := get(ctx, &up.client, listed.Links.DownloadLocation, up.headers())
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (up *UnsplashProvider) GetName() string {
+	switch {
+	case up.random:
+		return "unsplash-random"
+	case up.collection != "":
+		return fmt.Sprintf("unsplash-collection-%v", nameFromURL(up.collection))
+	case up.topic != "":
+		return fmt.Sprintf("unsplash-topic-%v", nameFromURL(up.topic))
+	}
+	return fmt.Sprintf("unsplash-search-%v", nameFromURL(up.query))
+}
+
+func (up *UnsplashProvider) SetStorageLocation(location string) {
+}
+
+func (up *UnsplashProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = up
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, up.interval)
+}
+
+func GetUnsplashPhotoProvider(config map[string]interface{}) PhotoProvider {
+	up := &UnsplashProvider{
+		baseURL:  "https://api.unsplash.com",
+		count:    10,
+		width:    unsplashDefaultWidth,
+		interval: pollInterval(config, 3600),
+		client:   http.Client{Timeout: time.Minute},
+	}
+	up.apiKey, _ = config["api_key"].(string)
+	if up.apiKey == "" {
+		up.apiKey = os.Getenv("UNSPLASH_ACCESS_KEY")
+	}
+	if up.apiKey == "" {
+		logger.Errorf("api_key config parameter or UNSPLASH_ACCESS_KEY have to be set")
+		return nil
+	}
+	if baseURL, ok := config["base_url"].(string); ok {
+		up.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	up.random, _ = config["random"].(bool)
+	up.collection, _ = config["collection"].(string)
+	up.topic, _ = config["topic"].(string)
+	up.query, _ = config["query"].(string)
+	sources := 0
+	for _, source := range []string{up.collection, up.topic, up.query} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources == 0 {
+		up.random = true
+	} else if sources > 1 && !up.random {
+		logger.Errorf("Only one of collection, topic and query can be used unless random is set")
+		return nil
+	}
+	if orientation, ok := config["orientation"].(string); ok {
+		if !isOneOf(orientation, unsplashOrientations) {
+			logger.Errorf("orientation config parameter %q is not one of %v", orientation, unsplashOrientations)
+			return nil
+		}
+		up.orientation = orientation
+	}
+	if count, ok := config["count"].(float64); ok && count > 0 {
+		up.count = int(count)
+	}
+	if width, ok := config["width"].(float64); ok && width > 0 {
+		up.width = int(width)
+	}
+	if height, ok := config["height"].(float64); ok && height > 0 {
+		up.height = int(height)
+	}
+
+	var pl PhotoProvider = &PhotoDownloader{backend: up, client: &up.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("unsplash", GetUnsplashPhotoProvider)
+}
@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/txomon/sawyer/pkg/util"
+)
+
+const testUnsplashPhoto = `{"id": "%[2]v", "description": "", "alt_description": "Photo %[2]v",
+	"urls": {"raw": "%[1]v/raw/%[2]v.png?ixid=1"},
+	"links": {"html": "https://unsplash.com/photos/%[2]v", "download_location": "%[1]v/track/%[2]v"},
+	"user": {"name": "Author", "links": {"html": "https://unsplash.com/@author"}}}`
+
+// unsplashServer answers the random and search endpoints with two photos
+func unsplashServer(t *testing.T) (*httptest.Server, *[]string) {
+	return testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		photos := fmt.Sprintf(testUnsplashPhoto, server.URL, "a") + "," + fmt.Sprintf(testUnsplashPhoto, server.URL, "b")
+		switch {
+		case request.URL.Path == "/photos/random":
+			fmt.Fprintf(writer, "[%v]", photos)
+		case request.URL.Path == "/search/photos":
+			fmt.Fprintf(writer, `{"results": [%v]}`, photos)
+		case strings.HasPrefix(request.URL.Path, "/track/"):
+			writer.Write([]byte("{}"))
+		default:
+			return false
+		}
+		if request.Header.Get("Authorization") != "Client-ID key" {
+			t.Errorf("API request %v without the key", request.URL)
+		}
+		return true
+	})
+}
+
+func TestUnsplashProvider(t *testing.T) {
+	util.RegisterSupportedFormat("png")
+	server, requests := unsplashServer(t)
+	provider := GetUnsplashPhotoProvider(map[string]interface{}{
+		"base_url":    server.URL,
+		"api_key":     "key",
+		"topic":       "nature",
+		"random":      true,
+		"orientation": "landscape",
+		"count":       2.0,
+		"width":       2560.0,
+	})
+	provider.SetStorageLocation(t.TempDir())
+	photos, err := provider.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 2 {
+		t.Fatalf("Expected 2 photos, got %v", photos)
+	}
+	expected := []string{
+		"/photos/random?count=2&orientation=landscape&topics=nature",
+		"/raw/a.png?fit=max&fm=jpg&ixid=1&q=85&w=2560",
+		"/track/a",
+		"/raw/b.png?fit=max&fm=jpg&ixid=1&q=85&w=2560",
+		"/track/b",
+	}
+	if !reflect.DeepEqual(*requests, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, *requests)
+	}
+	metadata, err := util.ReadMetadata(photos[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["title"] != "Photo a" || metadata["author"] != "Author" || metadata["url"] != "https://unsplash.com/photos/a?utm_source=sawyer&utm_medium=referral" {
+		t.Errorf("Unexpected attribution %v", metadata)
+	}
+
+	// Photos already downloaded are neither downloaded nor tracked again
+	*requests = nil
+	if _, err := provider.GetPhotos(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("Expected only the listing, got %v", *requests)
+	}
+}
+
+func TestUnsplashProviderSearch(t *testing.T) {
+	server, requests := unsplashServer(t)
+	up := GetUnsplashPhotoProvider(map[string]interface{}{
+		"base_url": server.URL,
+		"api_key":  "key",
+		"query":    "mountains",
+		"height":   1080.0,
+	}).(*PhotoDownloader).backend
+	photos, err := up.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 2 || !strings.Contains(photos[0], "fit=crop") || !strings.Contains(photos[0], "h=1080") {
+		t.Errorf("Expected cropped photos, got %v", photos)
+	}
+	if (*requests)[0] != "/search/photos?per_page=10&query=mountains" {
+		t.Errorf("Unexpected search %v", (*requests)[0])
+	}
+}
+
+func TestUnsplashProviderConfig(t *testing.T) {
+	original, set := os.LookupEnv("UNSPLASH_ACCESS_KEY")
+	os.Unsetenv("UNSPLASH_ACCESS_KEY")
+	if set {
+		defer os.Setenv("UNSPLASH_ACCESS_KEY", original)
+	}
+	for _, config := range []map[string]interface{}{
+		{"api_key": "key", "collection": "1", "query": "mountains"},
+		{"api_key": "key", "orientation": "round"},
+		{"query": "mountains"},
+	} {
+		if provider := GetUnsplashPhotoProvider(config); provider != nil {
+			t.Errorf("Config %v was accepted", config)
+		}
+	}
+}
+
+func TestUnsplashProviderNameIsPathSafe(t *testing.T) {
+	for provider, expected := range map[*UnsplashProvider]string{
+		{collection: "../317099"}:    "unsplash-collection-317099",
+		{topic: "nature/wallpapers"}: "unsplash-topic-naturewallpapers",
+		{query: "snowy mountains?x"}: "unsplash-search-snowymountainsx",
+		{random: true, query: "a/b"}: "unsplash-random",
+	} {
+		if name := provider.GetName(); name != expected {
+			t.Errorf("Expected %v, got %v", expected, name)
+		}
+	}
+}
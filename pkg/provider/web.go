@@ -0,0 +1,236 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Pages a crawl reads at most, so a page linking to itself in endless ways
+// can't keep it going.
+var webMaxPages = 200
+
+// htmlLinkTag matches the links and images of a page, htmlLink every
+// address in one of them. Besides href and src, data-href and data-src are
+// matched on purpose: lazy loading galleries keep the picture there and a
+// placeholder in src. Other attributes ending in src, like srcset, are not.
+var (
+	htmlLinkTag = regexp.MustCompile(`(?is)<(?:a|img)\b[^>]*>`)
+	htmlLink    = regexp.MustCompile(`(?is)\s(?:data-)?(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// WebProvider scrapes the pictures linked or shown in a page, like an
+// autoindex directory listing or an HTML gallery. Links ending in / below
+// the page are followed as sub-directories, up to depth levels.
+type WebProvider struct {
+	pageURL  *url.URL
+	include  []*regexp.Regexp
+	exclude  []*regexp.Regexp
+	depth    int
+	limit    int
+	interval time.Duration
+	client   http.Client
+
+	listed map[string]string
+}
+
+// links returns every href and src of the page, resolved against it
+func (wp *WebProvider) links(ctx context.Context, page *url.URL) ([]*url.URL, error) {
+	response, err := get(ctx, &wp.client, page.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	var links []*url.URL
+	for _, tag := range htmlLinkTag.FindAllString(string(content), -1) {
+		for _, match := range htmlLink.FindAllStringSubmatch(tag, -1) {
+			link := strings.TrimSpace(html.UnescapeString(match[1] + match[2] + match[3]))
+			reference, err := url.Parse(link)
+			if err != nil || link == "" || strings.HasPrefix(link, "#") {
+				continue
+			}
+			resolved := page.ResolveReference(reference)
+			resolved.Fragment = ""
+			links = append(links, resolved)
+		}
+	}
+	return links, nil
+}
+
+// isSubdirectory tells the links to follow: directories below the page
+// crawled first, parents and other sites are left alone.
+func (wp *WebProvider) isSubdirectory(link *url.URL) bool {
+	if link.Host != wp.pageURL.Host || link.RawQuery != "" || !strings.HasSuffix(link.Path, "/") {
+		return false
+	}
+	root := wp.pageURL.Path
+	if !strings.HasSuffix(root, "/") {
+		root = root[:strings.LastIndex(root, "/")+1]
+	}
+	return strings.HasPrefix(link.Path, root) && link.Path != root
+}
+
+func (wp *WebProvider) isPicture(link *url.URL) bool {
+	address := link.String()
+	for _, exclude := range wp.exclude {
+		if exclude.MatchString(address) {
+			return false
+		}
+	}
+	if len(wp.include) == 0 {
+		return isImageURL(address)
+	}
+	for _, include := range wp.include {
+		if include.MatchString(address) {
+			return true
+		}
+	}
+	return false
+}
+
+func (wp *WebProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	photos := make([]string, 0)
+	wp.listed = make(map[string]string)
+	visited := map[string]bool{wp.pageURL.String(): true}
+	pages := []*url.URL{wp.pageURL}
+	for depth := 0; depth <= wp.depth && len(pages) != 0; depth++ {
+		var next []*url.URL
+		for _, page := range pages {
+			links, err := wp.links(ctx, page)
+			if err != nil {
+				if page == wp.pageURL {
+					return nil, err
+				}
+				logger.Infof("Skipping page %v. %v", page, err)
+				continue
+			}
+			for _, link := range links {
+				address := link.String()
+				if wp.isSubdirectory(link) {
+					if !visited[address] && len(visited) < webMaxPages {
+						visited[address] = true
+						next = append(next, link)
+					}
+					continue
+				}
+				if _, ok := wp.listed[address]; ok || !wp.isPicture(link) {
+					continue
+				}
+				wp.listed[address] = page.String()
+				photos = append(photos, address)
+				if wp.limit > 0 && len(photos) >= wp.limit {
+					return photos, nil
+				}
+			}
+		}
+		pages = next
+	}
+	return photos, nil
+}
+
+func (wp *WebProvider) DescribePhoto(photo string) map[string]interface{} {
+	page, ok := wp.listed[photo]
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"source": "web",
+		"url":    page,
+	}
+}
+
+func (wp *WebProvider) GetName() string {
+	return fmt.Sprintf("web-%v", nameFromURL(wp.pageURL.String()))
+}
+
+func (wp *WebProvider) SetStorageLocation(location string) {
+}
+
+func (wp *WebProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = wp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, wp.interval)
+}
+
+// configPatterns compiles a config parameter holding a regular expression
+// or a list of them.
+func configPatterns(config map[string]interface{}, key string) ([]*regexp.Regexp, bool) {
+	var expressions []string
+	switch value := config[key].(type) {
+	case nil:
+		return nil, true
+	case string:
+		expressions = []string{value}
+	case []interface{}:
+		for _, item := range value {
+			expression, ok := item.(string)
+			if !ok {
+				logger.Errorf("%v config parameter has %v, which is not a string", key, item)
+				return nil, false
+			}
+			expressions = append(expressions, expression)
+		}
+	default:
+		logger.Errorf("%v config parameter is not a string nor a list of them", key)
+		return nil, false
+	}
+	var patterns []*regexp.Regexp
+	for _, expression := range expressions {
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			logger.Errorf("%v config parameter %q is not a valid regular expression. %v", key, expression, err)
+			return nil, false
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, true
+}
+
+func GetWebPhotoProvider(config map[string]interface{}) PhotoProvider {
+	pageURL, ok := config["url"].(string)
+	if !ok || pageURL == "" {
+		logger.Errorf("url config parameter is not a string as expected")
+		return nil
+	}
+	parsed, err := url.Parse(pageURL)
+	if err != nil || parsed.Host == "" {
+		logger.Errorf("url config parameter %q is not an absolute URL. %v", pageURL, err)
+		return nil
+	}
+	wp := &WebProvider{
+		pageURL:  parsed,
+		interval: pollInterval(config, 3600),
+		client:   http.Client{Timeout: time.Minute},
+	}
+	if wp.include, ok = configPatterns(config, "include"); !ok {
+		return nil
+	}
+	if wp.exclude, ok = configPatterns(config, "exclude"); !ok {
+		return nil
+	}
+	if depth, ok := config["depth"].(float64); ok && depth > 0 {
+		wp.depth = int(depth)
+	}
+	if limit, ok := config["limit"].(float64); ok && limit > 0 {
+		wp.limit = int(limit)
+	}
+
+	var pl PhotoProvider = &PhotoDownloader{backend: wp, client: &wp.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("web", GetWebPhotoProvider)
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// Directory listings of a gallery two levels deep, linking back to their
+// parents and out to other sites
+var testWebPages = map[string]string{
+	"/gallery/": `<a href="../">Parent</a> <a href="2025/">2025</a> <a href='cover.jpg'>Cover</a>
+		<img src="/gallery/thumbs/cover.jpg"> <a href="https://example.com/other/">Other</a> <a href="#top">Top</a>`,
+	"/gallery/2025/":        `<a href="/gallery/">Up</a> <a href="summer/">summer</a> <A HREF=beach.png>Beach</A> <a href="notes.txt">Notes</a>`,
+	"/gallery/2025/summer/": `<a href="sunset.jpg?size=large&amp;v=1">Sunset</a>`,
+}
+
+func webServer(t *testing.T) (*httptest.Server, *[]string) {
+	return testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		page, ok := testWebPages[request.URL.Path]
+		if !ok {
+			return false
+		}
+		fmt.Fprint(writer, page)
+		return true
+	})
+}
+
+func TestWebProviderCrawl(t *testing.T) {
+	server, requests := webServer(t)
+	tests := []struct {
+		config   map[string]interface{}
+		expected []string
+	}{
+		{
+			map[string]interface{}{},
+			[]string{"/gallery/cover.jpg", "/gallery/thumbs/cover.jpg"},
+		},
+		{
+			map[string]interface{}{"depth": 2.0},
+			[]string{"/gallery/cover.jpg", "/gallery/thumbs/cover.jpg", "/gallery/2025/beach.png", "/gallery/2025/summer/sunset.jpg?size=large&v=1"},
+		},
+		{
+			map[string]interface{}{"depth": 2.0, "exclude": "/thumbs/", "include": []interface{}{`\.jpg`, `\.txt$`}},
+			[]string{"/gallery/cover.jpg", "/gallery/2025/notes.txt", "/gallery/2025/summer/sunset.jpg?size=large&v=1"},
+		},
+		{
+			map[string]interface{}{"depth": 2.0, "limit": 3.0},
+			[]string{"/gallery/cover.jpg", "/gallery/thumbs/cover.jpg", "/gallery/2025/beach.png"},
+		},
+	}
+	for _, test := range tests {
+		test.config["url"] = server.URL + "/gallery/"
+		*requests = nil
+		wp := GetWebPhotoProvider(test.config).(*PhotoDownloader).backend
+		photos, err := wp.GetPhotos(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected []string
+		for _, photo := range test.expected {
+			expected = append(expected, server.URL+photo)
+		}
+		if !reflect.DeepEqual(photos, expected) {
+			t.Errorf("Crawling with %v gave %v, expected %v", test.config, photos, expected)
+		}
+		// Parents and other sites are never crawled
+		for _, request := range *requests {
+			if request == "/" {
+				t.Errorf("Crawling with %v read the parent page", test.config)
+			}
+		}
+	}
+}
+
+func TestWebProviderInvalidConfig(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"url": "/gallery/"},
+		{"url": "https://example.com/", "include": "[a-"},
+		{"url": "https://example.com/", "exclude": 1.0},
+	} {
+		if provider := GetWebPhotoProvider(config); provider != nil {
+			t.Errorf("Config %v was accepted", config)
+		}
+	}
+}
+
+func TestWebProviderLazyLoading(t *testing.T) {
+	server, _ := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		fmt.Fprint(writer, `<img class="lazy" src="/blank.gif" data-src="/photo.jpg" srcset="/small.jpg 640w">`)
+		return true
+	})
+	wp := GetWebPhotoProvider(map[string]interface{}{"url": server.URL + "/"}).(*PhotoDownloader).backend
+	photos, err := wp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The placeholder is not a picture format, srcset is left alone
+	if expected := []string{server.URL + "/photo.jpg"}; !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected %v, got %v", expected, photos)
+	}
+}
+
+func TestWebProviderMaxPages(t *testing.T) {
+	original := webMaxPages
+	t.Cleanup(func() { webMaxPages = original })
+	webMaxPages = 3
+	// Every page links to a deeper one, endlessly
+	server, requests := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		fmt.Fprintf(writer, `<a href="next/">Next</a> <a href="%v.jpg">Photo</a>`, len(request.URL.Path))
+		return true
+	})
+	wp := GetWebPhotoProvider(map[string]interface{}{"url": server.URL + "/", "depth": 10.0}).(*PhotoDownloader).backend
+	photos, err := wp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*requests) != 3 || len(photos) != 3 {
+		t.Errorf("Expected 3 pages crawled, got %v giving %v", *requests, photos)
+	}
+}
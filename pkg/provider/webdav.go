@@ -0,0 +1,256 @@
+package provider
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Folders a listing reads at most, like webMaxPages for web listings
+const webdavMaxFolders = 200
+
+const webdavPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:resourcetype/>
+    <d:getcontenttype/>
+    <d:getetag/>
+    <d:getlastmodified/>
+  </d:prop>
+</d:propfind>`
+
+// WebDAVProvider lists the pictures of a WebDAV folder, like a Nextcloud or
+// ownCloud one, and of its sub-folders up to depth levels. Files are only
+// downloaded again when their ETag, or their modification date when there
+// is none, changes.
+type WebDAVProvider struct {
+	folderURL *url.URL
+	username  string
+	password  string
+	depth     int
+	limit     int
+	interval  time.Duration
+	client    http.Client
+
+	listed map[string]webdavFile
+}
+
+type webdavMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string     `xml:"DAV: status"`
+			Prop   webdavFile `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+type webdavFile struct {
+	ResourceType struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+	ContentType  string `xml:"DAV: getcontenttype"`
+	ETag         string `xml:"DAV: getetag"`
+	LastModified string `xml:"DAV: getlastmodified"`
+	path         string
+}
+
+// DecorateRequest authenticates the request when there are credentials.
+// The password is usually an app password, and may be taken out of the
+// environment with {env:NAME}.
+func (wp *WebDAVProvider) DecorateRequest(request *http.Request) error {
+	if wp.username != "" {
+		request.SetBasicAuth(expandEnv(wp.username), expandEnv(wp.password))
+	}
+	return nil
+}
+
+// propfind lists the files right inside folder
+func (wp *WebDAVProvider) propfind(ctx context.Context, folder *url.URL) (map[string]webdavFile, error) {
+	request, err := http.NewRequestWithContext(ctx, "PROPFIND", folder.String(), strings.NewReader(webdavPropfind))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", userAgent)
+	request.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	request.Header.Set("Depth", "1")
+	if err := wp.DecorateRequest(request); err != nil {
+		return nil, err
+	}
+	response, err := wp.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusMultiStatus {
+		io.Copy(ioutil.Discard, response.Body)
+		return nil, StatusError{URL: folder.String(), StatusCode: response.StatusCode, Status: response.Status}
+	}
+	var multistatus webdavMultistatus
+	if err := xml.NewDecoder(response.Body).Decode(&multistatus); err != nil {
+		return nil, fmt.Errorf("parsing listing of %v: %v", folder, err)
+	}
+
+	files := make(map[string]webdavFile)
+	for _, entry := range multistatus.Responses {
+		reference, err := url.Parse(strings.TrimSpace(entry.Href))
+		if err != nil {
+			logger.Debugf("Skipping invalid href %q. %v", entry.Href, err)
+			continue
+		}
+		resolved := folder.ResolveReference(reference)
+		if strings.TrimSuffix(resolved.Path, "/") == strings.TrimSuffix(folder.Path, "/") {
+			continue
+		}
+		for _, propstat := range entry.Propstats {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			file := propstat.Prop
+			file.path = strings.TrimPrefix(resolved.Path, wp.folderURL.Path)
+			files[resolved.String()] = file
+		}
+	}
+	return files, nil
+}
+
+func (wf webdavFile) isImage(address string) bool {
+	if strings.HasPrefix(wf.ContentType, "image/") {
+		return true
+	}
+	return isImageURL(address)
+}
+
+func (wp *WebDAVProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	photos := make([]string, 0)
+	listed := make(map[string]webdavFile)
+	folders := []*url.URL{wp.folderURL}
+	visited := 1
+	for depth := 0; depth <= wp.depth && len(folders) != 0; depth++ {
+		var next []*url.URL
+		for _, folder := range folders {
+			files, err := wp.propfind(ctx, folder)
+			if err != nil {
+				if folder == wp.folderURL {
+					return nil, err
+				}
+				logger.Infof("Skipping folder %v. %v", folder, err)
+				continue
+			}
+			addresses := make([]string, 0, len(files))
+			for address := range files {
+				addresses = append(addresses, address)
+			}
+			sort.Strings(addresses)
+			for _, address := range addresses {
+				file := files[address]
+				if file.ResourceType.Collection != nil {
+					if subfolder, err := url.Parse(address); err == nil && visited < webdavMaxFolders {
+						visited++
+						next = append(next, subfolder)
+					}
+					continue
+				}
+				if !file.isImage(address) {
+					continue
+				}
+				listed[address] = file
+				photos = append(photos, address)
+				if wp.limit > 0 && len(photos) >= wp.limit {
+					wp.listed = listed
+					return photos, nil
+				}
+			}
+		}
+		folders = next
+	}
+	wp.listed = listed
+	return photos, nil
+}
+
+func (wp *WebDAVProvider) PhotoVersion(photo string) string {
+	file := wp.listed[photo]
+	if etag := strings.Trim(strings.TrimPrefix(file.ETag, "W/"), `"`); etag != "" {
+		return etag
+	}
+	return file.LastModified
+}
+
+func (wp *WebDAVProvider) DescribePhoto(photo string) map[string]interface{} {
+	file, ok := wp.listed[photo]
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"source":        "webdav",
+		"path":          file.path,
+		"etag":          wp.PhotoVersion(photo),
+		"last_modified": file.LastModified,
+	}
+}
+
+func (wp *WebDAVProvider) GetName() string {
+	return fmt.Sprintf("webdav-%v", nameFromURL(wp.folderURL.String()))
+}
+
+func (wp *WebDAVProvider) SetStorageLocation(location string) {
+}
+
+func (wp *WebDAVProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = wp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, wp.interval)
+}
+
+func GetWebDAVPhotoProvider(config map[string]interface{}) PhotoProvider {
+	folderURL, ok := config["url"].(string)
+	if !ok || folderURL == "" {
+		logger.Errorf("url config parameter is not a string as expected")
+		return nil
+	}
+	parsed, err := url.Parse(folderURL)
+	if err != nil || parsed.Host == "" {
+		logger.Errorf("url config parameter %q is not an absolute URL. %v", folderURL, err)
+		return nil
+	}
+	if !strings.HasSuffix(parsed.Path, "/") {
+		parsed.Path += "/"
+		parsed.RawPath = ""
+	}
+	wp := &WebDAVProvider{
+		folderURL: parsed,
+		interval:  pollInterval(config, 3600),
+		client:    http.Client{Timeout: time.Minute},
+	}
+	if parsed.User != nil {
+		wp.username = parsed.User.Username()
+		wp.password, _ = parsed.User.Password()
+		parsed.User = nil
+	}
+	if username, ok := config["username"].(string); ok && username != "" {
+		wp.username = username
+		wp.password, _ = config["password"].(string)
+	}
+	if depth, ok := config["depth"].(float64); ok && depth > 0 {
+		wp.depth = int(depth)
+	}
+	if limit, ok := config["limit"].(float64); ok && limit > 0 {
+		wp.limit = int(limit)
+	}
+
+	var pl PhotoProvider = &PhotoDownloader{backend: wp, client: &wp.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("webdav", GetWebDAVPhotoProvider)
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testWebDAVFolders are the PROPFIND answers, each listing the folder
+// itself first as servers do
+var testWebDAVFolders = map[string]string{
+	"/dav/photos/": `<d:response><d:href>/dav/photos/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+		<d:response><d:href>/dav/photos/b%20c.jpg</d:href><d:propstat><d:prop><d:resourcetype/><d:getcontenttype>image/jpeg</d:getcontenttype><d:getetag>W/"b1"</d:getetag></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+		<d:response><d:href>/dav/photos/a.bin</d:href><d:propstat><d:prop><d:resourcetype/><d:getcontenttype>image/png</d:getcontenttype><d:getlastmodified>Mon, 02 Jun 2025 10:00:00 GMT</d:getlastmodified></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+		<d:response><d:href>/dav/photos/notes.txt</d:href><d:propstat><d:prop><d:resourcetype/><d:getcontenttype>text/plain</d:getcontenttype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+		<d:response><d:href>/dav/photos/2025/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+	"/dav/photos/2025/": `<d:response><d:href>/dav/photos/2025/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+		<d:response><d:href>/dav/photos/2025/sea.jpg</d:href><d:propstat><d:prop><d:resourcetype/></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+		<d:response><d:href>/dav/photos/2025/gone.jpg</d:href><d:propstat><d:prop/><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>`,
+}
+
+func webdavServer(t *testing.T) (*httptest.Server, *[]string) {
+	return testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		if username, password, _ := request.BasicAuth(); username != "user" || password != "secret" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return true
+		}
+		if request.Method != "PROPFIND" {
+			return false
+		}
+		listing, ok := testWebDAVFolders[request.URL.Path]
+		if !ok || request.Header.Get("Depth") != "1" {
+			writer.WriteHeader(http.StatusNotFound)
+			return true
+		}
+		writer.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(writer, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">%v</d:multistatus>`, listing)
+		return true
+	})
+}
+
+func TestWebDAVProvider(t *testing.T) {
+	server, requests := webdavServer(t)
+	address := strings.Replace(server.URL, "://", "://user:secret@", 1)
+	provider := GetWebDAVPhotoProvider(map[string]interface{}{"url": address + "/dav/photos", "depth": 1.0})
+	wp := provider.(*PhotoDownloader).backend.(*WebDAVProvider)
+	photos, err := wp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		server.URL + "/dav/photos/a.bin",
+		server.URL + "/dav/photos/b%20c.jpg",
+		server.URL + "/dav/photos/2025/sea.jpg",
+	}
+	if !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected %v, got %v", expected, photos)
+	}
+	if !reflect.DeepEqual(*requests, []string{"/dav/photos/", "/dav/photos/2025/"}) {
+		t.Errorf("Unexpected folders listed %v", *requests)
+	}
+	if version := wp.PhotoVersion(photos[1]); version != "b1" {
+		t.Errorf("Expected the ETag as version, got %q", version)
+	}
+	if version := wp.PhotoVersion(photos[0]); version != "Mon, 02 Jun 2025 10:00:00 GMT" {
+		t.Errorf("Expected the modification date as version, got %q", version)
+	}
+	if path := wp.DescribePhoto(photos[2])["path"]; path != "2025/sea.jpg" {
+		t.Errorf("Unexpected path %v", path)
+	}
+}
+
+func TestWebDAVProviderCredentials(t *testing.T) {
+	server, _ := webdavServer(t)
+	wrong := GetWebDAVPhotoProvider(map[string]interface{}{"url": server.URL + "/dav/photos/", "username": "user", "password": "wrong"})
+	if _, err := wrong.(*PhotoDownloader).backend.GetPhotos(context.Background()); err == nil {
+		t.Errorf("Listing with a wrong password didn't fail")
+	}
+
+	original, set := os.LookupEnv("SAWYER_TEST_WEBDAV_PASSWORD")
+	os.Setenv("SAWYER_TEST_WEBDAV_PASSWORD", "secret")
+	defer func() {
+		if set {
+			os.Setenv("SAWYER_TEST_WEBDAV_PASSWORD", original)
+		} else {
+			os.Unsetenv("SAWYER_TEST_WEBDAV_PASSWORD")
+		}
+	}()
+	provider := GetWebDAVPhotoProvider(map[string]interface{}{
+		"url":      server.URL + "/dav/photos/",
+		"username": "user",
+		"password": "{env:SAWYER_TEST_WEBDAV_PASSWORD}",
+		"limit":    1.0,
+	})
+	photos, err := provider.(*PhotoDownloader).backend.GetPhotos(context.Background())
+	if err != nil || len(photos) != 1 {
+		t.Errorf("Expected one photo listed, got %v %v", photos, err)
+	}
+}
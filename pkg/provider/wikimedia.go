@@ -0,0 +1,274 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Titles the MediaWiki API takes at once in a query
+const wikimediaMaxTitles = 50
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// WikimediaProvider lists the Wikimedia Commons pictures of the day and
+// the files in a category, as thumbnails of the requested width. Their
+// licence and author are kept, as most need to be credited.
+type WikimediaProvider struct {
+	apiURL   string
+	potd     bool
+	days     int
+	category string
+	limit    int
+	width    int
+	interval time.Duration
+	client   http.Client
+
+	listed map[string]wikimediaFile
+}
+
+type wikimediaQuery struct {
+	Continue map[string]string `json:"continue"`
+	Query    struct {
+		Pages []struct {
+			Title     string          `json:"title"`
+			ImageInfo []wikimediaFile `json:"imageinfo"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+type wikimediaFile struct {
+	URL            string `json:"url"`
+	ThumbURL       string `json:"thumburl"`
+	DescriptionURL string `json:"descriptionurl"`
+	Mime           string `json:"mime"`
+	ExtMetadata    map[string]struct {
+		Value interface{} `json:"value"`
+	} `json:"extmetadata"`
+	title string
+}
+
+// metadata returns an extended metadata field as plain text
+func (wf wikimediaFile) metadata(field string) string {
+	value, ok := wf.ExtMetadata[field]
+	if !ok || value.Value == nil {
+		return ""
+	}
+	text := htmlTag.ReplaceAllString(fmt.Sprint(value.Value), "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+func (wp *WikimediaProvider) apiGet(ctx context.Context, params url.Values, value interface{}) error {
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+	return getJSON(ctx, &wp.client, fmt.Sprintf("%v?%v", wp.apiURL, params.Encode()), nil, value)
+}
+
+// query runs an imageinfo query following its continue tokens, until there
+// are no more results or limit files were found.
+func (wp *WikimediaProvider) query(ctx context.Context, params url.Values, limit int) ([]wikimediaFile, error) {
+	params.Set("action", "query")
+	params.Set("prop", "imageinfo")
+	params.Set("iiprop", "url|mime|extmetadata")
+	params.Set("iiurlwidth", fmt.Sprint(wp.width))
+	var files []wikimediaFile
+	continued := map[string]string{}
+	for {
+		// Each round only carries the latest continue values
+		round := url.Values{}
+		for key, values := range params {
+			round[key] = values
+		}
+		for key, value := range continued {
+			round.Set(key, value)
+		}
+		var result wikimediaQuery
+		if err := wp.apiGet(ctx, round, &result); err != nil {
+			return files, err
+		}
+		for _, page := range result.Query.Pages {
+			for _, file := range page.ImageInfo {
+				file.title = page.Title
+				files = append(files, file)
+			}
+		}
+		if len(result.Continue) == 0 || limit > 0 && len(files) >= limit {
+			return files, nil
+		}
+		continued = result.Continue
+	}
+}
+
+// potdTitles are the files that were the picture of the day the last days
+func (wp *WikimediaProvider) potdTitles(ctx context.Context) ([]string, error) {
+	var titles []string
+	today := time.Now().UTC()
+	for day := 0; day < wp.days; day++ {
+		date := today.AddDate(0, 0, -day).Format("2006-01-02")
+		params := url.Values{}
+		params.Set("action", "expandtemplates")
+		params.Set("prop", "wikitext")
+		params.Set("text", fmt.Sprintf("{{Potd/%v}}", date))
+		var result struct {
+			ExpandTemplates struct {
+				Wikitext string `json:"wikitext"`
+			} `json:"expandtemplates"`
+		}
+		if err := wp.apiGet(ctx, params, &result); err != nil {
+			return titles, err
+		}
+		name := strings.TrimSpace(result.ExpandTemplates.Wikitext)
+		if name == "" {
+			logger.Debugf("No Commons picture of the day for %v", date)
+			continue
+		}
+		titles = append(titles, "File:"+name)
+	}
+	return titles, nil
+}
+
+func (wp *WikimediaProvider) files(ctx context.Context) ([]wikimediaFile, error) {
+	var files []wikimediaFile
+	if wp.potd {
+		titles, err := wp.potdTitles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for start := 0; start < len(titles); start += wikimediaMaxTitles {
+			end := start + wikimediaMaxTitles
+			if end > len(titles) {
+				end = len(titles)
+			}
+			params := url.Values{}
+			params.Set("titles", strings.Join(titles[start:end], "|"))
+			potd, err := wp.query(ctx, params, 0)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, potd...)
+		}
+	}
+	if wp.category != "" {
+		params := url.Values{}
+		params.Set("generator", "categorymembers")
+		params.Set("gcmtitle", "Category:"+strings.TrimPrefix(wp.category, "Category:"))
+		params.Set("gcmtype", "file")
+		params.Set("gcmlimit", fmt.Sprint(wikimediaMaxTitles))
+		category, err := wp.query(ctx, params, wp.limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(category) > wp.limit {
+			category = category[:wp.limit]
+		}
+		files = append(files, category...)
+	}
+	return files, nil
+}
+
+func (wp *WikimediaProvider) GetPhotos(ctx context.Context) ([]string, error) {
+	files, err := wp.files(ctx)
+	if err != nil {
+		return nil, err
+	}
+	photos := make([]string, 0)
+	wp.listed = make(map[string]wikimediaFile)
+	for _, file := range files {
+		if !strings.HasPrefix(file.Mime, "image/") {
+			logger.Debugf("Skipping %v, it's a %v", file.title, file.Mime)
+			continue
+		}
+		photo := file.ThumbURL
+		if photo == "" {
+			photo = file.URL
+		}
+		if _, ok := wp.listed[photo]; ok || photo == "" {
+			continue
+		}
+		wp.listed[photo] = file
+		photos = append(photos, photo)
+	}
+	return photos, nil
+}
+
+func (wp *WikimediaProvider) DescribePhoto(photo string) map[string]interface{} {
+	file, ok := wp.listed[photo]
+	if !ok {
+		return nil
+	}
+	title := file.metadata("ObjectName")
+	if title == "" {
+		title = strings.TrimPrefix(file.title, "File:")
+	}
+	return map[string]interface{}{
+		"source":      "wikimedia",
+		"title":       title,
+		"author":      file.metadata("Artist"),
+		"credit":      file.metadata("Credit"),
+		"license":     file.metadata("LicenseShortName"),
+		"license_url": file.metadata("LicenseUrl"),
+		"url":         file.DescriptionURL,
+	}
+}
+
+func (wp *WikimediaProvider) GetName() string {
+	var name []string
+	if wp.potd {
+		name = append(name, "potd")
+	}
+	if wp.category != "" {
+		name = append(name, nameFromURL(strings.TrimPrefix(wp.category, "Category:")))
+	}
+	return fmt.Sprintf("wikimedia-%v", strings.Join(name, "-"))
+}
+
+func (wp *WikimediaProvider) SetStorageLocation(location string) {
+}
+
+func (wp *WikimediaProvider) Run(ctx context.Context, photoProvider *PhotoProvider) {
+	var pp PhotoProvider = wp
+	if photoProvider == nil {
+		photoProvider = &pp
+	}
+	poll(ctx, *photoProvider, wp.interval)
+}
+
+func GetWikimediaPhotoProvider(config map[string]interface{}) PhotoProvider {
+	wp := &WikimediaProvider{
+		apiURL:   "https://commons.wikimedia.org/w/api.php",
+		days:     1,
+		limit:    100,
+		width:    1920,
+		interval: pollInterval(config, 21600),
+		client:   http.Client{Timeout: time.Minute},
+	}
+	if apiURL, ok := config["base_url"].(string); ok && apiURL != "" {
+		wp.apiURL = apiURL
+	}
+	wp.category, _ = config["category"].(string)
+	wp.potd, _ = config["potd"].(bool)
+	if !wp.potd && wp.category == "" {
+		wp.potd = true
+	}
+	if days, ok := config["days"].(float64); ok && days > 0 {
+		wp.days = int(days)
+	}
+	if limit, ok := config["limit"].(float64); ok && limit > 0 {
+		wp.limit = int(limit)
+	}
+	if width, ok := config["width"].(float64); ok && width > 0 {
+		wp.width = int(width)
+	}
+
+	var pl PhotoProvider = &PhotoDownloader{backend: wp, client: &wp.client}
+	return pl
+}
+
+func init() {
+	RegisterProvider("wikimedia", GetWikimediaPhotoProvider)
+}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestWikimediaPictureOfTheDay(t *testing.T) {
+	server, requests := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		query := request.URL.Query()
+		switch query.Get("action") {
+		case "expandtemplates":
+			fmt.Fprint(writer, `{"expandtemplates": {"wikitext": "Sunset.jpg\n"}}`)
+		case "query":
+			if query.Get("titles") != "File:Sunset.jpg|File:Sunset.jpg" || query.Get("iiurlwidth") != "1280" {
+				t.Errorf("Unexpected query %v", query)
+			}
+			fmt.Fprintf(writer, `{"query": {"pages": [
+				{"title": "File:Sunset.jpg", "imageinfo": [{"url": "%[1]v/Sunset.jpg", "thumburl": "%[1]v/1280px-Sunset.jpg", "mime": "image/jpeg",
+					"descriptionurl": "https://commons.wikimedia.org/wiki/File:Sunset.jpg",
+					"extmetadata": {"Artist": {"value": "<a href=\"/wiki/User:Author\">Author &amp; co</a>"}, "LicenseShortName": {"value": "CC BY-SA 4.0"}}}]},
+				{"title": "File:Sunset.ogv", "imageinfo": [{"url": "%[1]v/Sunset.ogv", "mime": "video/ogg"}]}
+			]}}`, server.URL)
+		default:
+			return false
+		}
+		return true
+	})
+	wp := GetWikimediaPhotoProvider(map[string]interface{}{
+		"base_url": server.URL + "/w/api.php",
+		"days":     2.0,
+		"width":    1280.0,
+	}).(*PhotoDownloader).backend.(*WikimediaProvider)
+
+	photos, err := wp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{server.URL + "/1280px-Sunset.jpg"}; !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected photos %v, got %v", expected, photos)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("Expected a template per day and a query, got %v", *requests)
+	}
+	metadata := wp.DescribePhoto(photos[0])
+	if metadata["title"] != "Sunset.jpg" || metadata["author"] != "Author & co" || metadata["license"] != "CC BY-SA 4.0" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+	if wp.GetName() != "wikimedia-potd" {
+		t.Errorf("Unexpected name %v", wp.GetName())
+	}
+}
+
+func TestWikimediaCategoryLimit(t *testing.T) {
+	server, _ := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		if request.URL.Query().Get("gcmtitle") != "Category:Mountains" {
+			t.Errorf("Unexpected query %v", request.URL.Query())
+		}
+		fmt.Fprint(writer, `{"query": {"pages": [
+			{"title": "File:1.jpg", "imageinfo": [{"url": "https://example.com/1.jpg", "mime": "image/jpeg"}]},
+			{"title": "File:2.jpg", "imageinfo": [{"url": "https://example.com/2.jpg", "mime": "image/jpeg"}]},
+			{"title": "File:3.jpg", "imageinfo": [{"url": "https://example.com/3.jpg", "mime": "image/jpeg"}]}
+		]}}`)
+		return true
+	})
+	wp := GetWikimediaPhotoProvider(map[string]interface{}{
+		"base_url": server.URL,
+		"category": "Category:Mountains",
+		"limit":    2.0,
+	}).(*PhotoDownloader).backend
+	photos, err := wp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"https://example.com/1.jpg", "https://example.com/2.jpg"}; !reflect.DeepEqual(photos, expected) {
+		t.Errorf("Expected photos %v, got %v", expected, photos)
+	}
+}
+
+func TestWikimediaQueryContinues(t *testing.T) {
+	responses := []string{
+		`{"continue": {"gcmcontinue": "file|2", "continue": "gcmcontinue||"}, "query": {"pages": [{"title": "File:1.jpg", "imageinfo": [{"url": "https://example.com/1.jpg", "mime": "image/jpeg"}]}]}}`,
+		`{"continue": {"iistart": "2020", "continue": "||"}, "query": {"pages": [{"title": "File:2.jpg", "imageinfo": [{"url": "https://example.com/2.jpg", "mime": "image/jpeg"}]}]}}`,
+		`{"query": {"pages": [{"title": "File:3.jpg", "imageinfo": [{"url": "https://example.com/3.jpg", "mime": "image/jpeg"}]}]}}`,
+	}
+	var round int
+	server, requests := testServer(t, func(server *httptest.Server, writer http.ResponseWriter, request *http.Request) bool {
+		fmt.Fprint(writer, responses[round])
+		round++
+		return true
+	})
+
+	wp := &WikimediaProvider{apiURL: server.URL, category: "Test", limit: 10, width: 100}
+	photos, err := wp.GetPhotos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 3 || len(*requests) != 3 {
+		t.Fatalf("Expected 3 photos in 3 queries, got %v in %v", photos, *requests)
+	}
+	var queries []url.Values
+	for _, requested := range *requests {
+		parsed, _ := url.Parse(requested)
+		queries = append(queries, parsed.Query())
+	}
+	if queries[1].Get("gcmcontinue") != "file|2" {
+		t.Errorf("Second query didn't continue, got %v", queries[1])
+	}
+	if queries[2].Get("iistart") != "2020" || queries[2].Get("gcmcontinue") != "" || queries[2].Get("continue") != "||" {
+		t.Errorf("Third query didn't only carry the latest continue values, got %v", queries[2])
+	}
+	if queries[2].Get("gcmtitle") != "Category:Test" {
+		t.Errorf("Third query lost the base parameters, got %v", queries[2])
+	}
+}
+
+func TestWikimediaNameIsPathSafe(t *testing.T) {
+	wp := &WikimediaProvider{potd: true, category: "Category:Pictures of the day/2024"}
+	if name := wp.GetName(); name != "wikimedia-potd-Picturesoftheday2024" {
+		t.Errorf("Unexpected name %v", name)
+	}
+}
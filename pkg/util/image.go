@@ -0,0 +1,152 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func LoadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %v: %v", path, err)
+	}
+	return img, nil
+}
+
+// SaveImage encodes the image in the format given by the path extension.
+// It's written to a temporary file first, so whoever is reading path never
+// sees it half written.
+func SaveImage(img image.Image, path string) error {
+	return SaveImageFormat(img, path, filepath.Ext(path))
+}
+
+// SaveImageFormat is SaveImage for paths whose extension doesn't tell the
+// format, which is png, jpg or jpeg with or without the leading dot.
+func SaveImageFormat(img image.Image, path, format string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "png":
+		err = png.Encode(file, img)
+	case "jpg", "jpeg":
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: 92})
+	default:
+		err = fmt.Errorf("no encoder for %v as %q", path, format)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// ScaleToFill scales the image to cover width x height keeping its aspect
+// ratio, cropping whatever overflows evenly from both sides.
+func ScaleToFill(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	scale := math.Max(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	cropWidth := float64(width) / scale
+	cropHeight := float64(height) / scale
+	left := float64(bounds.Min.X) + (float64(bounds.Dx())-cropWidth)/2
+	top := float64(bounds.Min.Y) + (float64(bounds.Dy())-cropHeight)/2
+	return resample(toRGBA(src), left, top, 1/scale, width, height)
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(src.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+	return rgba
+}
+
+// resample builds a width x height image with bilinear interpolation,
+// pixel (x, y) of the result comes from (left + x*step, top + y*step).
+func resample(src *image.RGBA, left, top, step float64, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	bounds := src.Bounds()
+	clamp := func(value, min, max int) int {
+		if value < min {
+			return min
+		}
+		if value > max {
+			return max
+		}
+		return value
+	}
+	for y := 0; y < height; y++ {
+		sy := top + (float64(y)+0.5)*step - 0.5
+		y0 := int(math.Floor(sy))
+		fy := sy - float64(y0)
+		y1 := clamp(y0+1, bounds.Min.Y, bounds.Max.Y-1)
+		y0 = clamp(y0, bounds.Min.Y, bounds.Max.Y-1)
+		for x := 0; x < width; x++ {
+			sx := left + (float64(x)+0.5)*step - 0.5
+			x0 := int(math.Floor(sx))
+			fx := sx - float64(x0)
+			x1 := clamp(x0+1, bounds.Min.X, bounds.Max.X-1)
+			x0 = clamp(x0, bounds.Min.X, bounds.Max.X-1)
+
+			p00 := src.PixOffset(x0, y0)
+			p10 := src.PixOffset(x1, y0)
+			p01 := src.PixOffset(x0, y1)
+			p11 := src.PixOffset(x1, y1)
+			d := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				upper := float64(src.Pix[p00+c])*(1-fx) + float64(src.Pix[p10+c])*fx
+				lower := float64(src.Pix[p01+c])*(1-fx) + float64(src.Pix[p11+c])*fx
+				dst.Pix[d+c] = uint8(upper*(1-fy) + lower*fy + 0.5)
+			}
+		}
+	}
+	return dst
+}
+
+// Brightness is the average luma of the picture, from 0 for black to 1 for
+// white. It samples a grid of pixels, which is plenty to tell dark and light
+// pictures apart.
+func Brightness(path string) (float64, error) {
+	img, err := LoadImage(path)
+	if err != nil {
+		return 0, err
+	}
+	const samples = 64
+	bounds := img.Bounds()
+	var total float64
+	for sy := 0; sy < samples; sy++ {
+		y := bounds.Min.Y + (2*sy+1)*bounds.Dy()/(2*samples)
+		for sx := 0; sx < samples; sx++ {
+			x := bounds.Min.X + (2*sx+1)*bounds.Dx()/(2*samples)
+			r, g, b, _ := img.At(x, y).RGBA()
+			total += (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+		}
+	}
+	return total / (samples * samples), nil
+}
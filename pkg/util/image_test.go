@@ -0,0 +1,41 @@
+package util
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestBrightness(t *testing.T) {
+	directory := t.TempDir()
+	for _, test := range []struct {
+		fill     color.Gray
+		min, max float64
+	}{
+		{color.Gray{Y: 0}, 0, 0.01},
+		{color.Gray{Y: 128}, 0.49, 0.52},
+		{color.Gray{Y: 255}, 0.99, 1},
+	} {
+		picture := image.NewGray(image.Rect(0, 0, 100, 50))
+		for x := 0; x < 100; x++ {
+			for y := 0; y < 50; y++ {
+				picture.SetGray(x, y, test.fill)
+			}
+		}
+		path := filepath.Join(directory, "picture.png")
+		if err := SaveImage(picture, path); err != nil {
+			t.Fatal(err)
+		}
+		brightness, err := Brightness(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if brightness < test.min || brightness > test.max {
+			t.Errorf("Brightness of %v is %v, expected between %v and %v", test.fill, brightness, test.min, test.max)
+		}
+	}
+	if _, err := Brightness(filepath.Join(directory, "missing.png")); err == nil {
+		t.Errorf("Expected an error for a missing picture")
+	}
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MetadataExtension is appended to a photo path to name the sidecar file
+// where providers keep what they know about it, like its title or author.
+const MetadataExtension = ".json"
+
+func IsMetadata(path string) bool {
+	return strings.HasSuffix(path, MetadataExtension)
+}
+
+// ReadMetadata returns the sidecar metadata of a photo, nil when it has
+// none.
+func ReadMetadata(photo string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(photo + MetadataExtension)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func WriteMetadata(photo string, metadata map[string]interface{}) error {
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(photo+MetadataExtension, content)
+}
+
+// WriteFileAtomic writes through a temporary file renamed over path, so
+// readers never see it half written.
+func WriteFileAtomic(path string, content []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,30 @@
+package util
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	directory := t.TempDir()
+	photo := filepath.Join(directory, "photo.jpg")
+	if metadata, err := ReadMetadata(photo); metadata != nil || err != nil {
+		t.Errorf("Expected no metadata, got %v %v", metadata, err)
+	}
+	metadata := map[string]interface{}{"title": "Photo", "width": 1920.0}
+	if err := WriteMetadata(photo, metadata); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadMetadata(photo); err != nil || !reflect.DeepEqual(read, metadata) {
+		t.Errorf("Expected %v, got %v %v", metadata, read, err)
+	}
+	if !IsMetadata(photo + MetadataExtension) {
+		t.Errorf("Sidecar of %v not told apart", photo)
+	}
+	// Nothing is left behind from the atomic write
+	if files, _ := ioutil.ReadDir(directory); len(files) != 1 {
+		t.Errorf("Expected only the sidecar, got %v", files)
+	}
+}
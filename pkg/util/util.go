@@ -19,6 +19,23 @@ const (
 	ConfigurationChangeInterval = "change_interval"
 	ConfigurationCacheDir       = "cache_dir"
 	ConfigurationProviders      = "providers"
+	ConfigurationDesktop        = "desktop_environment"
+	ConfigurationDesktopOptions = "desktop_options"
+	ConfigurationWallpaperMode  = "wallpaper_mode"
+	ConfigurationSpanBezel      = "span_bezel"
+	ConfigurationExports        = "exports"
+	ConfigurationTargets        = "targets"
+
+	ConfigurationApplyRetries    = "apply_retries"
+	ConfigurationApplyRetryDelay = "apply_retry_delay"
+	ConfigurationApplyFallbacks  = "apply_fallbacks"
+
+	ConfigurationLockScreenEnabled        = "lock_screen.enabled"
+	ConfigurationLockScreenFollowDesktop  = "lock_screen.follow_desktop"
+	ConfigurationLockScreenProviders      = "lock_screen.providers"
+	ConfigurationLockScreenCacheDir       = "lock_screen.cache_dir"
+	ConfigurationLockScreenChangeInterval = "lock_screen.change_interval"
+	ConfigurationLockScreenOptions        = "lock_screen.options"
 )
 
 var supportedFormats []string
@@ -93,6 +110,11 @@ func IsBackground(backgroundDescriptors ...interface{}) string {
 }
 
 func RegisterSupportedFormat(format string) {
+	for _, supportedFormat := range supportedFormats {
+		if supportedFormat == format {
+			return
+		}
+	}
 	supportedFormats = append(supportedFormats, format)
 }
 
@@ -102,11 +124,30 @@ func GetPhotosForPath(path string) []string {
 		logger.Infof("Path %v doesn't exist, skipping", path)
 		return make([]string, 0)
 	}
+	root := path
 	filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Providers write and remove files while they are walked, those
+			// gone by now are just left out
+			logger.Tracef("Skipping %v. %v", path, err)
+			return nil
+		}
 		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				logger.Debugf("Skipping hidden dir %v", path)
+				return filepath.SkipDir
+			}
 			logger.Debugf("Photos can only be files, skipping dir %v", path)
 			return err
 		}
+		if IsMetadata(path) {
+			logger.Tracef("Skipping metadata file %v", path)
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			logger.Tracef("Skipping hidden file %v, like the ones being written", path)
+			return nil
+		}
 		file, err := filepath.Abs(path)
 		if err != nil {
 			logger.Errorf("Path %v could not be converted to absolute path: %v", path, err)
@@ -115,7 +156,7 @@ func GetPhotosForPath(path string) []string {
 		fileReader, err := os.Open(file)
 		if err != nil {
 			logger.Warningf("Couldn't open %v for supported format filtering. %v", path, err)
-			return err
+			return nil
 		}
 		defer fileReader.Close()
 
@@ -130,6 +171,12 @@ func GetPhotosForPath(path string) []string {
 	return fileList
 }
 
+// GetWorkDir is where sawyer keeps the pictures it generates, hidden so it
+// doesn't get listed along the ones providers store.
+func GetWorkDir(cacheDirectory string) string {
+	return filepath.Join(cacheDirectory, ".sawyer")
+}
+
 func CreateStorageDir(cacheDirectory, providerName string) string {
 	backendCacheDirectory := filepath.Join(cacheDirectory, providerName)
 	err := os.MkdirAll(backendCacheDirectory, 0755)
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestGetPhotosForPathSkipsMetadataAndTemporaryFiles(t *testing.T) {
+	RegisterSupportedFormat("png")
+	directory := t.TempDir()
+	picture := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	photo := filepath.Join(directory, "photo.png")
+	for _, path := range []string{photo, filepath.Join(directory, ".tmp-photo.png123")} {
+		if err := SaveImageFormat(picture, path, "png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteMetadata(photo, map[string]interface{}{"author": "someone"}); err != nil {
+		t.Fatal(err)
+	}
+
+	photos := GetPhotosForPath(directory)
+	if len(photos) != 1 || photos[0] != photo {
+		t.Errorf("Expected only %v, got %v", photo, photos)
+	}
+}
+
+func TestGetPhotosForPathWhileWriting(t *testing.T) {
+	directory := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(directory, "notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var writers sync.WaitGroup
+	stop := make(chan struct{})
+	for writer := 0; writer < 4; writer++ {
+		writers.Add(1)
+		go func(writer int) {
+			defer writers.Done()
+			path := filepath.Join(directory, fmt.Sprintf("memory-%v.json", writer))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := WriteFileAtomic(path, []byte("{}")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(writer)
+	}
+	for walk := 0; walk < 1000; walk++ {
+		if photos := GetPhotosForPath(directory); len(photos) != 0 {
+			t.Errorf("Expected no photos, got %v", photos)
+		}
+	}
+	close(stop)
+	writers.Wait()
+}